@@ -17,9 +17,11 @@ package action
 
 import (
 	"context"
+	"sync"
 
 	"github.com/cloudstateio/go-support/cloudstate/entity"
 	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
 )
 
@@ -36,6 +38,23 @@ type Context struct {
 	ctx      context.Context
 	command  *entity.ActionCommand
 	metadata *protocol.Metadata
+	// mu protects replyMetadata, which is taken by the emitter of a
+	// streamed handler on any goroutine, receiveErr and cutOff.
+	mu sync.Mutex
+	// replyMetadata replaces the commands metadata for replies and forwards.
+	replyMetadata *protocol.Metadata
+	// receiveErr is the error the client side of a full duplex stream
+	// ended with.
+	receiveErr error
+	// cutOff is set once the handler of the command has been cut off by its
+	// deadline, the context is no longer the one of the stream then.
+	cutOff bool
+	// interceptors intercept commands of the entity.
+	interceptors []protocol.Interceptor
+	// targets validates forward and side effect targets, if set.
+	targets *protocol.TargetValidation
+	// cmdCtx is the context.Context of the command currently handled.
+	cmdCtx context.Context
 
 	failure     error
 	response    *any.Any
@@ -44,11 +63,13 @@ type Context struct {
 
 	// respond is the function to be used for streamed responses.
 	respond RespondFunc
-	// cancel is the function called when a client closes a stream. With the
-	// then half-closed stream, the user function can choose to respond with
-	// an action response.
+	// cancel is the function called once when a client cancels a stream.
 	cancel CancelFunc
-	// close is called whenever a client closes a stream.
+	// cancelHandled is set once cancel has been handled.
+	cancelHandled bool
+	// close is called whenever a client closes a stream. With the then
+	// half-closed stream, the user function can choose to respond with an
+	// action response.
 	close     CloseFunc
 	cancelled bool
 }
@@ -65,21 +86,26 @@ func (c *Context) Forward(forward *protocol.Forward) {
 	c.forward = forward
 }
 
-func (c *Context) SideEffect(effect *protocol.SideEffect) {
-	c.sideEffects = append(c.sideEffects, effect)
+func (c *Context) SideEffect(effects ...*protocol.SideEffect) {
+	c.sideEffects = append(c.sideEffects, effects...)
 }
 
 // CloseFunc registers a function that is called whenever a client closes a
-// stream.
+// stream. A closed stream is half-closed and the function may respond.
 func (c *Context) CloseFunc(close CloseFunc) {
 	c.close = close
 }
 
+// CancellationFunc registers a function that is called once when a client
+// cancels a stream. The function may emit final side effects. As the stream
+// has been cancelled, they are sent on a best effort basis and a reply or
+// forward is not sent at all.
 func (c *Context) CancellationFunc(cancel CancelFunc) {
 	c.cancel = cancel
 }
 
-// Cancel cancels server command streaming.
+// Cancel ends the stream of a streamed out or full duplex streamed command
+// handled by HandleCommand once the current call returns.
 func (c *Context) Cancel() {
 	c.cancelled = true
 }
@@ -88,11 +114,69 @@ func (c *Context) Command() *entity.ActionCommand {
 	return c.command
 }
 
+// Metadata returns the metadata of the command. It is empty, not nil, for
+// a command without metadata.
 func (c *Context) Metadata() *protocol.Metadata {
+	if c.metadata == nil {
+		c.metadata = &protocol.Metadata{}
+	}
 	return c.metadata
 }
 
+// CommandCtx returns the context.Context of the command currently handled.
+// It has the deadline of the command, if any, and is done once the command
+// has been handled. It is the context passed on by the interceptors of the
+// command, for streamed handlers the one they are called with.
+func (c *Context) CommandCtx() context.Context {
+	if c.cmdCtx == nil {
+		return c.ctx
+	}
+	return c.cmdCtx
+}
+
+// ReplyMetadata returns the metadata attached to the reply or forward of
+// the command instead of the commands metadata, which is attached otherwise.
+// It starts as a copy of the commands metadata without its CloudEvents
+// attributes, as these describe the command and not the reply.
+func (c *Context) ReplyMetadata() *protocol.Metadata {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.replyMetadata == nil {
+		c.replyMetadata = c.command.GetMetadata().WithoutCloudEvent()
+	}
+	return c.replyMetadata
+}
+
+// ReplyCloudEvent returns a CloudEvents view on the reply metadata. Setting
+// its attributes is required when replies are published to an event
+// destination.
+func (c *Context) ReplyCloudEvent() *protocol.CloudEvent {
+	return c.ReplyMetadata().CloudEvent()
+}
+
+// ReceiveError returns the error the client side of a full duplex stream
+// ended with, once the channel of received messages has been closed. A
+// message that could not be decoded is a protocol.ClientError. It is nil
+// if the client closed its side of the stream.
+func (c *Context) ReceiveError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.receiveErr
+}
+
+func (c *Context) setReceiveError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.receiveErr = err
+}
+
 func (c *Context) Respond(err error) error {
+	c.mu.Lock()
+	cutOff := c.cutOff
+	c.mu.Unlock()
+	if cutOff {
+		return protocol.ClientError{Err: protocol.ErrCommandTimeout}
+	}
 	if c.respond != nil {
 		c.failure = err
 		return c.respond(c)
@@ -103,3 +187,39 @@ func (c *Context) Respond(err error) error {
 func (c *Context) respondFunc(respond RespondFunc) {
 	c.respond = respond
 }
+
+// successor returns a context that continues the stream of c once the
+// handler of the current command has been cut off by its deadline. It has
+// the state of c before the command.
+func (c *Context) successor() *Context {
+	next := &Context{
+		Entity:        c.Entity,
+		Instance:      c.Instance,
+		ctx:           c.ctx,
+		command:       c.command,
+		metadata:      c.metadata,
+		interceptors:  c.interceptors,
+		targets:       c.targets,
+		failure:       c.failure,
+		response:      c.response,
+		forward:       c.forward,
+		sideEffects:   append(make([]*protocol.SideEffect, 0, len(c.sideEffects)), c.sideEffects...),
+		respond:       c.respond,
+		cancel:        c.cancel,
+		cancelHandled: c.cancelHandled,
+		close:         c.close,
+		cancelled:     c.cancelled,
+	}
+	if c.replyMetadata != nil {
+		next.replyMetadata = proto.Clone(c.replyMetadata).(*protocol.Metadata)
+	}
+	return next
+}
+
+// cut marks the context as cut off from its stream. A response of the
+// handler still running with it is not sent.
+func (c *Context) cut() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cutOff = true
+}
@@ -16,6 +16,9 @@
 package action
 
 import (
+	"time"
+
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
 	"github.com/golang/protobuf/proto"
 )
 
@@ -25,8 +28,68 @@ type Entity struct {
 	ServiceName ServiceName
 	// EntityFunc creates a new entity.
 	EntityFunc func() EntityHandler
+	// Interceptors intercept the commands of the entity.
+	Interceptors []protocol.Interceptor
+	// Timeout bounds each call of HandleCommand if positive. A handler
+	// overrunning it is cut off and the command fails right away, the
+	// handler keeps its instance until it returns. Streamed handlers are
+	// not bounded.
+	Timeout time.Duration
+	// Singleton uses a single instance for all commands. The instance is
+	// called concurrently and has to be safe for concurrent use.
+	Singleton bool
+	// PoolSize bounds the instances used to handle commands if positive.
+	// An instance handles one command or stream at a time and is reused
+	// afterwards. Commands wait for an instance if all of them are in use.
+	// A streamed command holds its instance until the stream ends, so long
+	// running streams can keep unary commands waiting for as long.
+	PoolSize int
+}
+
+type Option func(s *Entity)
+
+func (e *Entity) Options(options ...Option) {
+	for _, opt := range options {
+		opt(e)
+	}
+}
+
+// WithInterceptors adds interceptors for the commands of the entity.
+func WithInterceptors(interceptors ...protocol.Interceptor) Option {
+	return func(e *Entity) {
+		e.Interceptors = append(e.Interceptors, interceptors...)
+	}
+}
+
+// WithTimeout bounds the time a command of the entity is handled. A
+// grpc-timeout or deadline metadata entry of a command may shorten it.
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Entity) {
+		e.Timeout = timeout
+	}
+}
+
+// WithSingleton uses a single instance for all commands of the entity.
+// The instance has to be safe for concurrent use.
+func WithSingleton() Option {
+	return func(e *Entity) {
+		e.Singleton = true
+	}
+}
+
+// WithPool uses a pool of at most size instances for the commands of the
+// entity. Each instance handles one command or stream at a time, and a
+// stream holds its instance until it ends. The pool has to be sized for the
+// concurrent streams expected in addition to unary commands.
+func WithPool(size int) Option {
+	return func(e *Entity) {
+		e.PoolSize = size
+	}
 }
 
+// EntityHandler handles the commands of an action. By default an instance
+// handles a single command or stream. Per command state belongs to the
+// Context, as instances may be reused with WithSingleton and WithPool.
 type EntityHandler interface {
 	HandleCommand(ctx *Context, name string, msg proto.Message) error
 }
@@ -0,0 +1,75 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+)
+
+// instances provides instances of an entity to handle commands. By default
+// a new instance is created for each command or stream.
+type instances struct {
+	entity *Entity
+	// singleton is the instance shared by all commands, if set.
+	singleton EntityHandler
+	// slots bounds the instances of a pool, free holds the idle ones.
+	slots chan struct{}
+	free  chan EntityHandler
+}
+
+func newInstances(e *Entity) *instances {
+	i := &instances{entity: e}
+	switch {
+	case e.Singleton:
+		i.singleton = e.EntityFunc()
+	case e.PoolSize > 0:
+		i.slots = make(chan struct{}, e.PoolSize)
+		i.free = make(chan EntityHandler, e.PoolSize)
+	}
+	return i
+}
+
+// acquire returns an instance and a function to release it when the
+// command or stream has been handled. Acquiring an instance of an
+// exhausted pool waits until an instance is released or ctx is done.
+func (i *instances) acquire(ctx context.Context) (EntityHandler, func(), error) {
+	if i.singleton != nil {
+		return i.singleton, func() {}, nil
+	}
+	if i.free == nil {
+		return i.entity.EntityFunc(), func() {}, nil
+	}
+	select {
+	case instance := <-i.free:
+		return instance, i.release(instance), nil
+	default:
+	}
+	select {
+	case instance := <-i.free:
+		return instance, i.release(instance), nil
+	case i.slots <- struct{}{}:
+		instance := i.entity.EntityFunc()
+		return instance, i.release(instance), nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+func (i *instances) release(instance EntityHandler) func() {
+	return func() {
+		i.free <- instance
+	}
+}
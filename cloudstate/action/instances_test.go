@@ -0,0 +1,148 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// countedEntity counts the instances created of it.
+type countedEntity struct {
+	id int
+}
+
+func (e *countedEntity) HandleCommand(*Context, string, proto.Message) error {
+	return nil
+}
+
+func countedEntityFunc(created *int) func() EntityHandler {
+	return func() EntityHandler {
+		*created++
+		return &countedEntity{id: *created}
+	}
+}
+
+// watchEntity handles unary commands and streams until they are cancelled.
+type watchEntity struct {
+	streaming chan struct{}
+}
+
+func (e *watchEntity) HandleCommand(*Context, string, proto.Message) error {
+	return nil
+}
+
+func (e *watchEntity) HandleStreamedOut(ctx context.Context, c *Context, name string, msg proto.Message, out *Emitter) error {
+	close(e.streaming)
+	<-ctx.Done()
+	return nil
+}
+
+func TestInstances(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("a singleton is reused", func(t *testing.T) {
+		var created int
+		i := newInstances(&Entity{EntityFunc: countedEntityFunc(&created), Singleton: true})
+		first, release, err := i.acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, _, err := i.acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+		if first != second || created != 1 {
+			t.Fatalf("first: %v, second: %v, created: %v; want one instance", first, second, created)
+		}
+	})
+
+	t.Run("a released instance of a pool is reused", func(t *testing.T) {
+		var created int
+		i := newInstances(&Entity{EntityFunc: countedEntityFunc(&created), PoolSize: 2})
+		first, release, err := i.acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+		second, _, err := i.acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first != second || created != 1 {
+			t.Fatalf("first: %v, second: %v, created: %v; want one instance", first, second, created)
+		}
+	})
+
+	t.Run("an exhausted pool waits for a released instance", func(t *testing.T) {
+		var created int
+		i := newInstances(&Entity{EntityFunc: countedEntityFunc(&created), PoolSize: 1})
+		first, release, err := i.acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, _, err := i.acquire(timeout); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err: %v; want: %v", err, context.DeadlineExceeded)
+		}
+		acquired := make(chan EntityHandler)
+		go func() {
+			instance, _, err := i.acquire(ctx)
+			if err != nil {
+				t.Error(err)
+			}
+			acquired <- instance
+		}()
+		release()
+		select {
+		case second := <-acquired:
+			if first != second || created != 1 {
+				t.Fatalf("first: %v, second: %v, created: %v; want one instance", first, second, created)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the released instance was not acquired")
+		}
+	})
+
+	t.Run("a stream holds its pooled instance until it ends", func(t *testing.T) {
+		streaming := make(chan struct{})
+		s := newTestServer(t, func() EntityHandler {
+			return &watchEntity{streaming: streaming}
+		}, WithPool(1))
+		st := newStream()
+		done := make(chan error)
+		go func() {
+			done <- s.HandleStreamedOut(command("Watch", message("a")), st)
+		}()
+		<-streaming
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		if _, err := s.HandleUnary(timeout, command("Get", message("a"))); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err: %v; want: %v", err, context.DeadlineExceeded)
+		}
+		st.cancel()
+		<-done
+		if _, err := s.HandleUnary(ctx, command("Get", message("a"))); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -45,6 +45,13 @@ type Server struct {
 	mu sync.RWMutex
 	// entities has descriptions of entities registered by service names
 	entities map[ServiceName]*Entity
+	// instances provide instances of the entities by service names.
+	instances map[ServiceName]*instances
+
+	// interceptors intercept commands of all entities.
+	interceptors []protocol.Interceptor
+	// targets validates forward and side effect targets, if set.
+	targets *protocol.TargetValidation
 
 	// internal marker enforced by go-grpc.
 	entity.UnimplementedActionProtocolServer
@@ -52,7 +59,8 @@ type Server struct {
 
 func NewServer() *Server {
 	return &Server{
-		entities: make(map[ServiceName]*Entity),
+		entities:  make(map[ServiceName]*Entity),
+		instances: make(map[ServiceName]*instances),
 	}
 }
 
@@ -60,15 +68,56 @@ func (s *Server) Register(e *Entity) error {
 	if e.EntityFunc == nil {
 		return errors.New("the entity has to define an EntityFunc but did not")
 	}
+	if e.Singleton && e.PoolSize > 0 {
+		return errors.New("the entity can either be a singleton or use a pool but not both")
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if _, ok := s.entities[e.ServiceName]; ok {
 		return fmt.Errorf("an entity with service name: %q is already registered", e.ServiceName)
 	}
 	s.entities[e.ServiceName] = e
+	s.instances[e.ServiceName] = newInstances(e)
 	return nil
 }
 
+// Intercept adds interceptors for commands of all entities of the server.
+// They run before the interceptors of an entity.
+func (s *Server) Intercept(interceptors ...protocol.Interceptor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+func (s *Server) globalInterceptors() []protocol.Interceptor {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.interceptors
+}
+
+// ValidateTargets sets the validation of forward and side effect targets
+// for all entities of the server.
+func (s *Server) ValidateTargets(v *protocol.TargetValidation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets = v
+}
+
+func (s *Server) targetValidation() *protocol.TargetValidation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.targets
+}
+
+// instanceFor returns an instance of the entity and a function to release
+// it after use.
+func (s *Server) instanceFor(ctx context.Context, e *Entity) (EntityHandler, func(), error) {
+	s.mu.RLock()
+	i := s.instances[e.ServiceName]
+	s.mu.RUnlock()
+	return i.acquire(ctx)
+}
+
 func (s *Server) entityFor(service ServiceName) (*Entity, error) {
 	s.mu.RLock()
 	e, ok := s.entities[service]
@@ -88,15 +137,22 @@ func (s *Server) HandleUnary(ctx context.Context, command *entity.ActionCommand)
 	if err != nil {
 		return nil, err
 	}
+	instance, release, err := s.instanceFor(ctx, e)
+	if err != nil {
+		return nil, err
+	}
 	r := runner{context: &Context{
 		Entity:      e,
-		Instance:    e.EntityFunc(),
+		Instance:    instance,
 		ctx:         ctx,
 		command:     command,
 		metadata:    command.Metadata,
 		sideEffects: make([]*protocol.SideEffect, 0),
 	}}
-	err = r.runCommand(command)
+	defer r.whenIdle(release)
+	r.context.interceptors = protocol.Interceptors(s.globalInterceptors(), e.Interceptors)
+	r.context.targets = s.targetValidation()
+	_, err = r.runCommand(command)
 	if err != nil && !errors.Is(err, protocol.ClientError{}) {
 		return nil, err
 	}
@@ -137,14 +193,21 @@ func (s *Server) HandleStreamedIn(stream entity.ActionProtocol_HandleStreamedInS
 	if err != nil {
 		return err
 	}
+	instance, release, err := s.instanceFor(stream.Context(), e)
+	if err != nil {
+		return err
+	}
 	r := runner{context: &Context{
 		Entity:      e,
-		Instance:    e.EntityFunc(),
+		Instance:    instance,
 		ctx:         stream.Context(),
 		command:     first,
 		metadata:    first.Metadata,
 		sideEffects: make([]*protocol.SideEffect, 0),
 	}}
+	defer r.whenIdle(release)
+	r.context.interceptors = protocol.Interceptors(s.globalInterceptors(), e.Interceptors)
+	r.context.targets = s.targetValidation()
 	for {
 		cmd, err := stream.Recv()
 		if err == io.EOF {
@@ -164,9 +227,10 @@ func (s *Server) HandleStreamedIn(stream entity.ActionProtocol_HandleStreamedInS
 			return nil
 		}
 		if err != nil {
+			r.handleCancellation(stream.SendAndClose)
 			return err
 		}
-		err = r.runCommand(cmd)
+		_, err = r.runCommand(cmd)
 		if err != nil {
 			r.context.failure = err
 		}
@@ -182,19 +246,32 @@ func (s *Server) HandleStreamedIn(stream entity.ActionProtocol_HandleStreamedInS
 //
 // Either the client or the server may cancel the stream at any time,
 // cancellation is indicated through an HTTP2 stream RST message.
+//
+// Instances implementing StreamedOutHandler handle the command once,
+// otherwise HandleCommand is called until the context is cancelled.
 func (s *Server) HandleStreamedOut(command *entity.ActionCommand, stream entity.ActionProtocol_HandleStreamedOutServer) error {
 	e, err := s.entityFor(ServiceName(command.ServiceName))
 	if err != nil {
 		return err
 	}
+	instance, release, err := s.instanceFor(stream.Context(), e)
+	if err != nil {
+		return err
+	}
 	r := runner{context: &Context{
 		Entity:      e,
-		Instance:    e.EntityFunc(),
+		Instance:    instance,
 		ctx:         stream.Context(),
 		command:     command,
 		metadata:    command.Metadata,
 		sideEffects: make([]*protocol.SideEffect, 0),
 	}}
+	defer r.whenIdle(release)
+	r.context.interceptors = protocol.Interceptors(s.globalInterceptors(), e.Interceptors)
+	r.context.targets = s.targetValidation()
+	if h, ok := r.context.Instance.(StreamedOutHandler); ok {
+		return r.runStreamedOut(h, command, stream)
+	}
 	r.context.respondFunc(func(c *Context) error {
 		r.response, err = r.actionResponse()
 		if err != nil {
@@ -208,17 +285,26 @@ func (s *Server) HandleStreamedOut(command *entity.ActionCommand, stream entity.
 		r.context.forward = nil
 		r.context.failure = nil
 		r.context.sideEffects = make([]*protocol.SideEffect, 0)
+		r.context.replyMetadata = nil
 		return nil
 	})
 	for {
 		// No matter what error runCommand returns here, we take it as an error
 		// to stop the stream as errors are sent through action.Context.Respond.
-		if err = r.runCommand(command); err != nil {
+		handled, err := r.runCommand(command)
+		if err != nil {
 			return err
 		}
-		if r.context.cancelled {
+		// A command short-circuited by an interceptor or timed out got a
+		// failure and ends the stream, as it would be handled the same way
+		// again.
+		if r.context.cancelled || !handled {
 			return nil
 		}
+		if err := stream.Context().Err(); err != nil {
+			r.handleCancellation(stream.Send)
+			return err
+		}
 	}
 }
 
@@ -249,6 +335,9 @@ func (s *Server) HandleStreamedOut(command *entity.ActionCommand, stream entity.
 //
 // Either the client or the server may cancel the stream at any time,
 // cancellation is indicated through an HTTP2 stream RST message.
+//
+// Instances implementing StreamedHandler handle the stream once, otherwise
+// HandleCommand is called for every message received.
 func (s *Server) HandleStreamed(stream entity.ActionProtocol_HandleStreamedServer) error {
 	first, err := stream.Recv()
 	if err != nil {
@@ -258,14 +347,24 @@ func (s *Server) HandleStreamed(stream entity.ActionProtocol_HandleStreamedServe
 	if err != nil {
 		return err
 	}
+	instance, release, err := s.instanceFor(stream.Context(), e)
+	if err != nil {
+		return err
+	}
 	r := runner{context: &Context{
 		Entity:      e,
-		Instance:    e.EntityFunc(),
+		Instance:    instance,
 		ctx:         stream.Context(),
 		command:     first,
 		metadata:    first.Metadata,
 		sideEffects: make([]*protocol.SideEffect, 0),
 	}}
+	defer r.whenIdle(release)
+	r.context.interceptors = protocol.Interceptors(s.globalInterceptors(), e.Interceptors)
+	r.context.targets = s.targetValidation()
+	if h, ok := r.context.Instance.(StreamedHandler); ok {
+		return r.runStreamed(h, stream)
+	}
 	r.context.respondFunc(func(c *Context) error {
 		r.response, err = r.actionResponse()
 		if err != nil {
@@ -279,6 +378,7 @@ func (s *Server) HandleStreamed(stream entity.ActionProtocol_HandleStreamedServe
 		r.context.response = nil
 		r.context.forward = nil
 		r.context.sideEffects = make([]*protocol.SideEffect, 0)
+		r.context.replyMetadata = nil
 		return nil
 	})
 	for {
@@ -293,44 +393,220 @@ func (s *Server) HandleStreamed(stream entity.ActionProtocol_HandleStreamedServe
 			return nil
 		}
 		if err != nil {
+			r.handleCancellation(stream.Send)
 			return err
 		}
 		cmd.ServiceName = r.context.command.ServiceName
 		cmd.Name = r.context.command.Name
 		cmd.Metadata = r.context.command.Metadata
-		if err = r.runCommand(cmd); err != nil {
+		if _, err = r.runCommand(cmd); err != nil {
 			r.context.failure = err
 		}
+		if r.context.cancelled {
+			return nil
+		}
 	}
 }
 
 type runner struct {
 	context  *Context
 	response *entity.ActionResponse
+	// pending is closed once the handler of a command cut off by its
+	// deadline returned, nil if there is none.
+	pending <-chan struct{}
 }
 
 // runCommand responds with effects, a response, a forward or a
-// failure using the action.Context passed to the command handler.
-func (r *runner) runCommand(cmd *entity.ActionCommand) error {
-	// unmarshal the commands message
+// failure using the action.Context passed to the command handler. It
+// returns false if the command has not been handled, as it has been
+// short-circuited by an interceptor or timed out. Such a command of a
+// stream gets a failure response.
+//
+// A handler that overruns its deadline is cut off, the command fails and
+// the handler is left running with a context of its own. A following
+// command of the stream waits for it, as an instance handles one command
+// at a time.
+func (r *runner) runCommand(cmd *entity.ActionCommand) (bool, error) {
+	message, err := decode(cmd)
+	if err != nil {
+		return false, err
+	}
+	if err := r.wait(); err != nil {
+		return false, err
+	}
+	ctx, cancel := protocol.CommandContext(r.context.ctx, r.context.Entity.Timeout, cmd.Metadata)
+	defer func() {
+		cancel()
+		r.context.cmdCtx = nil
+	}()
+	c := r.context
+	c.cmdCtx = ctx
+	// next takes over the stream if the handler is cut off. It is taken
+	// before the handler runs, as c is the handlers then.
+	next := c.successor()
+	handled := false
+	handlerCtx := make(chan context.Context, 1)
+	done := make(chan error, 1)
+	finished := make(chan struct{})
+	ic := r.interceptedCommand(cmd, message)
+	go func() {
+		defer close(finished)
+		done <- protocol.Intercept(ctx, ic, c.interceptors, func(ctx context.Context, ic *protocol.InterceptedCommand) error {
+			handled = true
+			c.cmdCtx = ctx
+			handlerCtx <- ctx
+			return c.Instance.HandleCommand(c, ic.Name, ic.Message)
+		})
+	}()
+	cutOff, err := await(ctx, handlerCtx, done)
+	if cutOff {
+		c.cut()
+		r.context = next
+		r.pending = finished
+		return false, r.fail(protocol.ClientError{Err: protocol.ErrCommandTimeout})
+	}
+	if forward := ic.Forwarded(); forward != nil {
+		c.Forward(forward)
+	}
+	// The deadline may have been tightened by an interceptor.
+	if timedOut := protocol.TimedOut(c.cmdCtx, nil); timedOut != nil {
+		// A command that overran its deadline fails without side effects.
+		c.sideEffects = make([]*protocol.SideEffect, 0)
+		return false, r.fail(timedOut)
+	}
+	if !handled {
+		return false, r.fail(err)
+	}
+	return true, err
+}
+
+// fail returns err for a command that has not been handled. A streamed
+// command gets a failure response for it instead.
+func (r *runner) fail(err error) error {
+	if r.context.respond != nil {
+		return r.context.Respond(err)
+	}
+	return err
+}
+
+// await waits for the result of a handler started with ctx. The handler
+// is cut off and true returned once the deadline of ctx, or the one of the
+// context the handler has been called with by the interceptors, has been
+// exceeded. A handler cancelled otherwise is waited for.
+func await(ctx context.Context, handlerCtx <-chan context.Context, done <-chan error) (bool, error) {
+	for {
+		select {
+		case err := <-done:
+			return false, err
+		case hctx := <-handlerCtx:
+			ctx, handlerCtx = hctx, nil
+		case <-ctx.Done():
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return false, <-done
+			}
+			return true, nil
+		}
+	}
+}
+
+// wait waits for the handler of a command cut off by its deadline or until
+// the stream is done.
+func (r *runner) wait() error {
+	if r.pending == nil {
+		return nil
+	}
+	select {
+	case <-r.pending:
+		r.pending = nil
+		return nil
+	case <-r.context.ctx.Done():
+		return r.context.ctx.Err()
+	}
+}
+
+// whenIdle calls release once no handler of a command cut off by its
+// deadline uses the instance anymore.
+func (r *runner) whenIdle(release func()) {
+	if r.pending == nil {
+		release()
+		return
+	}
+	pending := r.pending
+	go func() {
+		<-pending
+		release()
+	}()
+}
+
+func (r *runner) interceptedCommand(cmd *entity.ActionCommand, msg proto.Message) *protocol.InterceptedCommand {
+	return &protocol.InterceptedCommand{
+		Kind:        protocol.Action,
+		ServiceName: cmd.ServiceName,
+		Name:        cmd.Name,
+		Message:     msg,
+		Metadata:    cmd.Metadata,
+	}
+}
+
+// decode unmarshals the message of a command. JSON messages are returned
+// as they are.
+func decode(cmd *entity.ActionCommand) (proto.Message, error) {
 	msgName := strings.TrimPrefix(cmd.GetPayload().GetTypeUrl(), "type.googleapis.com/")
 	if strings.HasPrefix(msgName, "json.cloudstate.io/") {
-		return r.context.Instance.HandleCommand(r.context, cmd.Name, cmd.Payload)
+		return cmd.Payload, nil
 	}
 	messageType := proto.MessageType(msgName)
 	message, ok := reflect.New(messageType.Elem()).Interface().(proto.Message)
 	if !ok {
-		return fmt.Errorf("messageType is no proto.Message: %v", messageType)
+		return nil, fmt.Errorf("messageType is no proto.Message: %v", messageType)
 	}
 	if err := proto.Unmarshal(cmd.Payload.Value, message); err != nil {
-		return err
+		return nil, err
+	}
+	return message, nil
+}
+
+// replyMetadata returns the metadata for replies and forwards.
+func (r *runner) replyMetadata() *protocol.Metadata {
+	if r.context.replyMetadata != nil {
+		return r.context.replyMetadata
 	}
-	return r.context.Instance.HandleCommand(r.context, cmd.Name, message)
+	return r.context.command.Metadata
+}
+
+// handleCancellation calls the registered CancelFunc once if the client has
+// cancelled the stream and sends the side effects it emitted. The stream is
+// cancelled, so sending them is best effort and its error is ignored.
+func (r *runner) handleCancellation(send func(*entity.ActionResponse) error) {
+	c := r.context
+	if c.cancel == nil || c.cancelHandled || !errors.Is(c.ctx.Err(), context.Canceled) {
+		return
+	}
+	c.cancelHandled = true
+	c.response = nil
+	c.forward = nil
+	c.failure = nil
+	c.sideEffects = make([]*protocol.SideEffect, 0)
+	if err := c.cancel(c); err != nil || len(c.sideEffects) == 0 {
+		return
+	}
+	_ = send(&entity.ActionResponse{
+		SideEffects: c.sideEffects,
+	})
 }
 
 // actionResponse returns an action response depending on the runners
 // current state.
 func (r *runner) actionResponse() (*entity.ActionResponse, error) {
+	if r.context.failure == nil {
+		if err := r.context.targets.Check(r.context.forward, r.context.sideEffects); err != nil {
+			if !errors.Is(err, protocol.ClientError{}) {
+				return nil, err
+			}
+			r.context.failure = err
+			r.context.sideEffects = make([]*protocol.SideEffect, 0)
+		}
+	}
 	if r.context.failure != nil {
 		return &entity.ActionResponse{
 			Response: &entity.ActionResponse_Failure{
@@ -346,14 +622,14 @@ func (r *runner) actionResponse() (*entity.ActionResponse, error) {
 			Response: &entity.ActionResponse_Reply{
 				Reply: &protocol.Reply{
 					Payload:  r.context.response,
-					Metadata: r.context.command.Metadata,
+					Metadata: r.replyMetadata(),
 				},
 			},
 			SideEffects: r.context.sideEffects,
 		}, nil
 	}
 	if r.context.forward != nil {
-		r.context.forward.Metadata = r.context.command.Metadata
+		r.context.forward.Metadata = r.replyMetadata()
 		return &entity.ActionResponse{
 			Response: &entity.ActionResponse_Forward{
 				Forward: r.context.forward,
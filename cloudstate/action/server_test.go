@@ -0,0 +1,235 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+)
+
+type ctxKey struct{}
+
+// tenant returns an interceptor putting a tenant on the context.
+func tenant(name string) protocol.Interceptor {
+	return func(ctx context.Context, cmd *protocol.InterceptedCommand, next protocol.CommandHandler) error {
+		return next(context.WithValue(ctx, ctxKey{}, name), cmd)
+	}
+}
+
+func TestInterceptorContext(t *testing.T) {
+	s := newTestServer(t, func() EntityHandler {
+		return handlerFunc(func(c *Context, name string, msg proto.Message) error {
+			v, _ := c.CommandCtx().Value(ctxKey{}).(string)
+			c.RespondWith(encoding.String(v))
+			return nil
+		})
+	}, WithInterceptors(tenant("acme")))
+	resp, err := s.HandleUnary(context.Background(), command("Tenant", message("a")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := encoding.DecodeString(resp.GetReply().GetPayload()); got != "acme" {
+		t.Fatalf("reply: %q; want: %q", got, "acme")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	t.Run("reply metadata starts as a copy of the command metadata without CloudEvents attributes", func(t *testing.T) {
+		s := newTestServer(t, func() EntityHandler {
+			return handlerFunc(func(c *Context, name string, msg proto.Message) error {
+				c.ReplyCloudEvent().SetType("com.example.replied")
+				c.RespondWith(encoding.String("r"))
+				return nil
+			})
+		})
+		cmd := command("Reply", message("a"))
+		cmd.Metadata = &protocol.Metadata{}
+		cmd.Metadata.CloudEvent().SetSubject("subject")
+		cmd.Metadata.Set("tenant", "t")
+		resp, err := s.HandleUnary(context.Background(), cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		md := resp.GetReply().GetMetadata()
+		if tenant, _ := md.Get("tenant"); tenant != "t" {
+			t.Fatalf("reply metadata: %v; want the tenant", md)
+		}
+		if ce := md.CloudEvent(); ce.Subject() != "" || ce.Type() != "com.example.replied" {
+			t.Fatalf("reply metadata: %v; want the type and not the commands subject", md)
+		}
+		if _, ok := cmd.Metadata.Get(protocol.CloudEventType); ok {
+			t.Fatal("the command metadata should not be changed")
+		}
+	})
+
+	t.Run("the metadata of a command without metadata keeps writes", func(t *testing.T) {
+		var subject string
+		s := newTestServer(t, func() EntityHandler {
+			return handlerFunc(func(c *Context, name string, msg proto.Message) error {
+				c.Metadata().CloudEvent().SetSubject("subject")
+				subject = c.Metadata().CloudEvent().Subject()
+				return nil
+			})
+		})
+		if _, err := s.HandleUnary(context.Background(), command("Set", message("a"))); err != nil {
+			t.Fatal(err)
+		}
+		if subject != "subject" {
+			t.Fatalf("subject: %q; want: %q", subject, "subject")
+		}
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	slow := func() EntityHandler {
+		return handlerFunc(func(c *Context, name string, msg proto.Message) error {
+			<-c.CommandCtx().Done()
+			c.RespondWith(encoding.String("late"))
+			return nil
+		})
+	}
+	check := func(t *testing.T, resp *entity.ActionResponse, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := protocol.ClientError{Err: protocol.ErrCommandTimeout}.Error()
+		if got := resp.GetFailure().GetDescription(); got != want {
+			t.Fatalf("failure: %q; want: %q", got, want)
+		}
+	}
+
+	t.Run("a command overrunning the timeout of the entity fails", func(t *testing.T) {
+		s := newTestServer(t, slow, WithTimeout(10*time.Millisecond))
+		resp, err := s.HandleUnary(context.Background(), command("Slow", message("a")))
+		check(t, resp, err)
+	})
+
+	t.Run("a command that never finishes is cut off", func(t *testing.T) {
+		block := make(chan struct{})
+		defer close(block)
+		s := newTestServer(t, func() EntityHandler {
+			return handlerFunc(func(c *Context, name string, msg proto.Message) error {
+				<-block
+				return nil
+			})
+		}, WithTimeout(10*time.Millisecond))
+		resp, err := s.HandleUnary(context.Background(), command("Hang", message("a")))
+		check(t, resp, err)
+	})
+
+	t.Run("a cut off command of a duplex stream fails and the stream stays open", func(t *testing.T) {
+		block := make(chan struct{})
+		s := newTestServer(t, func() EntityHandler {
+			return handlerFunc(func(c *Context, name string, msg proto.Message) error {
+				v := msg.(*wrappers.StringValue).Value
+				if v == "hang" {
+					<-block
+				}
+				c.RespondWith(encoding.String(v))
+				return c.Respond(nil)
+			})
+		}, WithTimeout(50*time.Millisecond))
+		st := newStream(command("Chat", nil), command("", message("hang")), command("", message("b")))
+		done := make(chan error, 1)
+		go func() {
+			done <- s.HandleStreamed(st)
+		}()
+		for len(st.responses()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		// the cut off handler is waited for before the next command.
+		close(block)
+		close(st.in)
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		out := st.responses()
+		want := protocol.ClientError{Err: protocol.ErrCommandTimeout}.Error()
+		if len(out) != 2 || out[0].GetFailure().GetDescription() != want {
+			t.Fatalf("responses: %v; want a timeout failure and a reply", out)
+		}
+		if got := st.replies(); len(got) != 1 || got[0] != "b" {
+			t.Fatalf("replies: %v; want: %v", got, []string{"b"})
+		}
+	})
+
+	t.Run("a command overrunning a deadline of an interceptor fails", func(t *testing.T) {
+		s := newTestServer(t, slow, WithInterceptors(func(ctx context.Context, cmd *protocol.InterceptedCommand, next protocol.CommandHandler) error {
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			return next(ctx, cmd)
+		}))
+		resp, err := s.HandleUnary(context.Background(), command("Slow", message("a")))
+		check(t, resp, err)
+	})
+}
+
+func TestShortCircuitedStream(t *testing.T) {
+	denied := errors.New("denied")
+	deny := WithInterceptors(func(ctx context.Context, cmd *protocol.InterceptedCommand, next protocol.CommandHandler) error {
+		return protocol.ClientError{Err: denied}
+	})
+	handler := func() EntityHandler {
+		return handlerFunc(func(c *Context, name string, msg proto.Message) error {
+			c.RespondWith(encoding.String("handled"))
+			return c.Respond(nil)
+		})
+	}
+	want := protocol.ClientError{Err: denied}.Error()
+
+	t.Run("a duplex stream fails the command and stays open", func(t *testing.T) {
+		s := newTestServer(t, handler, deny)
+		st := newStream(command("Chat", nil), command("", message("a")), command("", message("b")))
+		close(st.in)
+		if err := s.HandleStreamed(st); err != nil {
+			t.Fatal(err)
+		}
+		out := st.responses()
+		if len(out) != 2 || out[0].GetFailure().GetDescription() != want || out[1].GetFailure().GetDescription() != want {
+			t.Fatalf("responses: %v; want a failure per command", out)
+		}
+	})
+
+	t.Run("a streamed out command fails and its stream ends", func(t *testing.T) {
+		s := newTestServer(t, handler, deny)
+		st := newStream()
+		done := make(chan error, 1)
+		go func() {
+			done <- s.HandleStreamedOut(command("Watch", message("a")), st)
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			st.cancel()
+			t.Fatal("the stream did not end")
+		}
+		if out := st.responses(); len(out) != 1 || out[0].GetFailure().GetDescription() != want {
+			t.Fatalf("responses: %v; want one failure", out)
+		}
+	})
+}
@@ -0,0 +1,232 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// A StreamedOutHandler handles server streamed commands. If the instance of
+// an entity implements StreamedOutHandler, HandleStreamedOut is called
+// exactly once per streamed out command instead of HandleCommand. Responses
+// are sent using the emitter and the stream ends when HandleStreamedOut
+// returns. A reply, forward or side effects set on the context are sent
+// last, once HandleStreamedOut returned. ctx is done when the client cancels
+// the stream, a CancelFunc registered on the context is called after
+// HandleStreamedOut returned.
+type StreamedOutHandler interface {
+	HandleStreamedOut(ctx context.Context, c *Context, name string, msg proto.Message, out *Emitter) error
+}
+
+// A StreamedHandler handles full duplex streamed commands. If the instance of
+// an entity implements StreamedHandler, HandleStreamed is called exactly once
+// per stream instead of HandleCommand. The messages sent by the client are
+// received from in, which is closed when the client closes its side of the
+// stream or a message could not be received, see Context.ReceiveError. A
+// receive error not returned by HandleStreamed ends the stream. Responses are sent using the emitter and the stream ends when
+// HandleStreamed returns. A reply, forward or side effects set on the
+// context are sent last, once HandleStreamed returned. ctx is done when the
+// stream is cancelled, a CancelFunc registered on the context is called
+// after HandleStreamed returned.
+type StreamedHandler interface {
+	HandleStreamed(ctx context.Context, c *Context, name string, in <-chan proto.Message, out *Emitter) error
+}
+
+// An Emitter sends responses of a streamed command. It is safe to be used by
+// multiple goroutines. A reply or forward takes the reply metadata of the
+// context set until then, so the metadata should be set by the goroutine
+// sending it.
+type Emitter struct {
+	mu          sync.Mutex
+	r           *runner
+	send        func(*entity.ActionResponse) error
+	sideEffects []*protocol.SideEffect
+}
+
+// SideEffect adds side effects to be sent with the next response.
+func (e *Emitter) SideEffect(effects ...*protocol.SideEffect) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sideEffects = append(e.sideEffects, effects...)
+}
+
+// Reply sends a reply with the pending side effects. The reply metadata of
+// the context is sent with the reply and reset afterwards.
+func (e *Emitter) Reply(payload *any.Any) error {
+	return e.emit(func(c *Context) {
+		c.RespondWith(payload)
+	})
+}
+
+// Forward sends a forward with the pending side effects.
+func (e *Emitter) Forward(forward *protocol.Forward) error {
+	return e.emit(func(c *Context) {
+		c.Forward(forward)
+	})
+}
+
+// Fail sends a failure with the pending side effects.
+func (e *Emitter) Fail(err error) error {
+	return e.emit(func(c *Context) {
+		c.failure = err
+	})
+}
+
+// Effects sends the pending side effects without a reply. It is a
+// `no operation` if there are no side effects pending.
+func (e *Emitter) Effects() error {
+	e.mu.Lock()
+	empty := len(e.sideEffects) == 0
+	e.mu.Unlock()
+	if empty {
+		return nil
+	}
+	return e.emit(func(*Context) {})
+}
+
+func (e *Emitter) emit(set func(c *Context)) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.r.context.mu.Lock()
+	md := e.r.context.replyMetadata
+	e.r.context.replyMetadata = nil
+	e.r.context.mu.Unlock()
+	c := &Context{
+		command:       e.r.context.command,
+		sideEffects:   e.sideEffects,
+		replyMetadata: md,
+		targets:       e.r.context.targets,
+	}
+	set(c)
+	r := runner{context: c}
+	response, err := r.actionResponse()
+	if err != nil {
+		return err
+	}
+	if err := e.send(response); err != nil {
+		return err
+	}
+	e.sideEffects = make([]*protocol.SideEffect, 0)
+	return nil
+}
+
+// runStreamedOut runs a streamed out command with a StreamedOutHandler.
+func (r *runner) runStreamedOut(h StreamedOutHandler, command *entity.ActionCommand, stream entity.ActionProtocol_HandleStreamedOutServer) error {
+	msg, err := decode(command)
+	if err != nil {
+		return err
+	}
+	out := &Emitter{r: r, send: stream.Send, sideEffects: make([]*protocol.SideEffect, 0)}
+	ic := r.interceptedCommand(command, msg)
+	err = protocol.Intercept(stream.Context(), ic, r.context.interceptors, func(ctx context.Context, ic *protocol.InterceptedCommand) error {
+		r.context.cmdCtx = ctx
+		return h.HandleStreamedOut(ctx, r.context, ic.Name, ic.Message, out)
+	})
+	r.context.cmdCtx = nil
+	if forward := ic.Forwarded(); forward != nil && err == nil {
+		err = out.Forward(forward)
+	}
+	if ctxErr := stream.Context().Err(); ctxErr != nil {
+		r.handleCancellation(stream.Send)
+		return ctxErr
+	}
+	return r.endStream(out, err)
+}
+
+// runStreamed runs a full duplex streamed command with a StreamedHandler.
+func (r *runner) runStreamed(h StreamedHandler, stream entity.ActionProtocol_HandleStreamedServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	in := make(chan proto.Message)
+	// The receiving goroutine is not joined, as Recv blocks until the
+	// client sends or the stream ends. gRPC ends the stream and its Recv
+	// once the handler returns, and ctx is cancelled then too, so the
+	// goroutine never blocks on in after that.
+	go func() {
+		defer close(in)
+		for ctx.Err() == nil {
+			cmd, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				// the stream is broken, so the handler is cancelled.
+				r.context.setReceiveError(err)
+				cancel()
+				return
+			}
+			msg, err := decode(cmd)
+			if err != nil {
+				r.context.setReceiveError(protocol.ClientError{Err: err})
+				return
+			}
+			select {
+			case in <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	out := &Emitter{r: r, send: stream.Send, sideEffects: make([]*protocol.SideEffect, 0)}
+	ic := r.interceptedCommand(r.context.command, nil)
+	err := protocol.Intercept(ctx, ic, r.context.interceptors, func(ctx context.Context, ic *protocol.InterceptedCommand) error {
+		r.context.cmdCtx = ctx
+		return h.HandleStreamed(ctx, r.context, ic.Name, in, out)
+	})
+	r.context.cmdCtx = nil
+	if forward := ic.Forwarded(); forward != nil && err == nil {
+		err = out.Forward(forward)
+	}
+	if ctxErr := stream.Context().Err(); ctxErr != nil {
+		r.handleCancellation(stream.Send)
+		return ctxErr
+	}
+	// A receive error the handler did not return ends the stream, a
+	// message not decoded with a failure.
+	if recvErr := r.context.ReceiveError(); recvErr != nil && (err == nil || errors.Is(err, context.Canceled)) {
+		err = recvErr
+	}
+	return r.endStream(out, err)
+}
+
+// endStream sends pending side effects and a failure for a client error
+// returned by a streamed handler, or the reply or forward set on the
+// context by the handler.
+func (r *runner) endStream(out *Emitter, err error) error {
+	if err != nil && !errors.Is(err, protocol.ClientError{}) {
+		return err
+	}
+	c := r.context
+	out.SideEffect(c.sideEffects...)
+	c.sideEffects = make([]*protocol.SideEffect, 0)
+	switch {
+	case err != nil:
+		return out.Fail(err)
+	case c.forward != nil:
+		return out.Forward(c.forward)
+	case c.response != nil:
+		return out.Reply(c.response)
+	}
+	return out.Effects()
+}
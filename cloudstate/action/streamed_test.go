@@ -0,0 +1,472 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+)
+
+// stream is a server stream of the action protocol. Commands are received
+// from in until it is closed and responses are collected.
+type stream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	cancel context.CancelFunc
+	in     chan *entity.ActionCommand
+
+	mu  sync.Mutex
+	out []*entity.ActionResponse
+}
+
+func newStream(in ...*entity.ActionCommand) *stream {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &stream{
+		ctx:    ctx,
+		cancel: cancel,
+		in:     make(chan *entity.ActionCommand, len(in)+8),
+	}
+	for _, cmd := range in {
+		s.in <- cmd
+	}
+	return s
+}
+
+func (s *stream) Recv() (*entity.ActionCommand, error) {
+	select {
+	case cmd, ok := <-s.in:
+		if !ok {
+			return nil, io.EOF
+		}
+		return cmd, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+func (s *stream) Send(out *entity.ActionResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.out = append(s.out, out)
+	return nil
+}
+
+func (s *stream) SendAndClose(out *entity.ActionResponse) error {
+	return s.Send(out)
+}
+
+func (s *stream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *stream) responses() []*entity.ActionResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*entity.ActionResponse(nil), s.out...)
+}
+
+func (s *stream) replies() []string {
+	replies := make([]string, 0)
+	for _, out := range s.responses() {
+		if reply := out.GetReply(); reply != nil {
+			replies = append(replies, encoding.DecodeString(reply.GetPayload()))
+		}
+	}
+	return replies
+}
+
+// message returns a StringValue message as payload of a command.
+func message(s string) *any.Any {
+	payload, err := encoding.MarshalAny(&wrappers.StringValue{Value: s})
+	if err != nil {
+		panic(err)
+	}
+	return payload
+}
+
+func command(name string, payload *any.Any) *entity.ActionCommand {
+	return &entity.ActionCommand{ServiceName: "test.Action", Name: name, Payload: payload}
+}
+
+func newTestServer(t *testing.T, f func() EntityHandler, options ...Option) *Server {
+	t.Helper()
+	e := &Entity{ServiceName: "test.Action", EntityFunc: f}
+	e.Options(options...)
+	s := NewServer()
+	if err := s.Register(e); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+var effect = &protocol.SideEffect{ServiceName: "test.Other", CommandName: "Notify"}
+
+type streamedFunc func(ctx context.Context, c *Context, name string, in <-chan proto.Message, out *Emitter) error
+
+func (f streamedFunc) HandleCommand(*Context, string, proto.Message) error {
+	return errors.New("HandleCommand called for a StreamedHandler")
+}
+
+func (f streamedFunc) HandleStreamed(ctx context.Context, c *Context, name string, in <-chan proto.Message, out *Emitter) error {
+	return f(ctx, c, name, in, out)
+}
+
+type streamedOutFunc func(ctx context.Context, c *Context, name string, msg proto.Message, out *Emitter) error
+
+func (f streamedOutFunc) HandleCommand(*Context, string, proto.Message) error {
+	return errors.New("HandleCommand called for a StreamedOutHandler")
+}
+
+func (f streamedOutFunc) HandleStreamedOut(ctx context.Context, c *Context, name string, msg proto.Message, out *Emitter) error {
+	return f(ctx, c, name, msg, out)
+}
+
+func TestStreamedHandler(t *testing.T) {
+	t.Run("messages received are replied to", func(t *testing.T) {
+		s := newTestServer(t, func() EntityHandler {
+			return streamedFunc(func(ctx context.Context, c *Context, name string, in <-chan proto.Message, out *Emitter) error {
+				for msg := range in {
+					if err := out.Reply(encoding.String(msg.(*wrappers.StringValue).Value)); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		})
+		st := newStream(command("Echo", nil), command("", message("a")), command("", message("b")))
+		close(st.in)
+		if err := s.HandleStreamed(st); err != nil {
+			t.Fatal(err)
+		}
+		if got := st.replies(); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+			t.Fatalf("replies: %v; want: %v", got, []string{"a", "b"})
+		}
+	})
+
+	t.Run("the receiving goroutine ends with the stream", func(t *testing.T) {
+		for _, pending := range []int{0, 1} {
+			var received <-chan proto.Message
+			s := newTestServer(t, func() EntityHandler {
+				return streamedFunc(func(ctx context.Context, c *Context, name string, in <-chan proto.Message, out *Emitter) error {
+					received = in
+					return nil
+				})
+			})
+			st := newStream(command("Ignore", nil))
+			for i := 0; i < pending; i++ {
+				st.in <- command("", message("a"))
+			}
+			if err := s.HandleStreamed(st); err != nil {
+				t.Fatal(err)
+			}
+			// gRPC cancels the stream once the handler returned.
+			st.cancel()
+			timeout := time.After(time.Second)
+			for ended := false; !ended; {
+				select {
+				case _, ok := <-received:
+					ended = !ok
+				case <-timeout:
+					t.Fatalf("the receiving goroutine did not end with %d messages pending", pending)
+				}
+			}
+		}
+	})
+
+	t.Run("an emitter is safe for concurrent use", func(t *testing.T) {
+		const n = 10
+		s := newTestServer(t, func() EntityHandler {
+			return streamedFunc(func(ctx context.Context, c *Context, name string, in <-chan proto.Message, out *Emitter) error {
+				var wg sync.WaitGroup
+				for i := 0; i < n; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						out.SideEffect(effect)
+						_ = out.Reply(encoding.String("r"))
+					}()
+				}
+				for i := 0; i < n; i++ {
+					c.ReplyMetadata()
+				}
+				wg.Wait()
+				return nil
+			})
+		})
+		st := newStream(command("Concurrent", nil))
+		close(st.in)
+		if err := s.HandleStreamed(st); err != nil {
+			t.Fatal(err)
+		}
+		effects := 0
+		for _, out := range st.responses() {
+			effects += len(out.GetSideEffects())
+		}
+		if got := len(st.replies()); got != n || effects != n {
+			t.Fatalf("replies: %v, side effects: %v; want: %v each", got, effects, n)
+		}
+	})
+}
+
+// brokenStream is a stream whose Recv fails with err once its commands
+// have been received.
+type brokenStream struct {
+	*stream
+	err error
+}
+
+func (s *brokenStream) Recv() (*entity.ActionCommand, error) {
+	if len(s.in) == 0 {
+		return nil, s.err
+	}
+	return s.stream.Recv()
+}
+
+func TestReceiveError(t *testing.T) {
+	undecodable := &any.Any{TypeUrl: "type.googleapis.com/google.protobuf.StringValue", Value: []byte{0xff}}
+	count := func(returned *error) func() EntityHandler {
+		return func() EntityHandler {
+			return streamedFunc(func(ctx context.Context, c *Context, name string, in <-chan proto.Message, out *Emitter) error {
+				n := 0
+				for range in {
+					n++
+				}
+				if err := out.Reply(encoding.String(fmt.Sprintf("%d", n))); err != nil {
+					return err
+				}
+				if returned != nil {
+					*returned = c.ReceiveError()
+					return *returned
+				}
+				return nil
+			})
+		}
+	}
+	check := func(t *testing.T, st *stream) {
+		t.Helper()
+		out := st.responses()
+		if len(out) != 2 || out[1].GetFailure() == nil {
+			t.Fatalf("responses: %v; want a reply and a failure", out)
+		}
+		if got := st.replies(); got[0] != "1" {
+			t.Fatalf("replies: %v; want: %v", got, []string{"1"})
+		}
+	}
+
+	t.Run("a message not decoded closes in and is returned by the handler", func(t *testing.T) {
+		var returned error
+		s := newTestServer(t, count(&returned))
+		st := newStream(command("Count", nil), command("", message("a")), command("", undecodable), command("", message("b")))
+		if err := s.HandleStreamed(st); err != nil {
+			t.Fatal(err)
+		}
+		if !errors.Is(returned, protocol.ClientError{}) {
+			t.Fatalf("c.ReceiveError(): %v; want: a client error", returned)
+		}
+		check(t, st)
+	})
+
+	t.Run("a message not decoded fails the stream if not returned by the handler", func(t *testing.T) {
+		s := newTestServer(t, count(nil))
+		st := newStream(command("Count", nil), command("", message("a")), command("", undecodable))
+		if err := s.HandleStreamed(st); err != nil {
+			t.Fatal(err)
+		}
+		check(t, st)
+	})
+
+	t.Run("a broken stream is returned", func(t *testing.T) {
+		broken := errors.New("broken")
+		s := newTestServer(t, func() EntityHandler {
+			return streamedFunc(func(ctx context.Context, c *Context, name string, in <-chan proto.Message, out *Emitter) error {
+				for range in {
+				}
+				<-ctx.Done()
+				return ctx.Err()
+			})
+		})
+		st := &brokenStream{stream: newStream(command("Drain", nil), command("", message("a"))), err: broken}
+		if err := s.HandleStreamed(st); !errors.Is(err, broken) {
+			t.Fatalf("err: %v; want: %v", err, broken)
+		}
+	})
+}
+
+func TestStreamedOutHandler(t *testing.T) {
+	s := newTestServer(t, func() EntityHandler {
+		return streamedOutFunc(func(ctx context.Context, c *Context, name string, msg proto.Message, out *Emitter) error {
+			v := msg.(*wrappers.StringValue).Value
+			for i := 0; i < 2; i++ {
+				if err := out.Reply(encoding.String(v)); err != nil {
+					return err
+				}
+			}
+			out.SideEffect(effect)
+			return nil
+		})
+	})
+	st := newStream()
+	if err := s.HandleStreamedOut(command("Repeat", message("a")), st); err != nil {
+		t.Fatal(err)
+	}
+	out := st.responses()
+	if got := st.replies(); len(got) != 2 || got[0] != "a" || got[1] != "a" {
+		t.Fatalf("replies: %v; want: %v", got, []string{"a", "a"})
+	}
+	if len(out) != 3 || len(out[2].GetSideEffects()) != 1 {
+		t.Fatalf("responses: %v; want the pending side effect sent last", out)
+	}
+}
+
+func TestStreamedContextResponses(t *testing.T) {
+	t.Run("a forward of the context is sent last", func(t *testing.T) {
+		forward := &protocol.Forward{ServiceName: "test.Other", CommandName: "Handle"}
+		s := newTestServer(t, func() EntityHandler {
+			return streamedOutFunc(func(ctx context.Context, c *Context, name string, msg proto.Message, out *Emitter) error {
+				if err := out.Reply(encoding.String("a")); err != nil {
+					return err
+				}
+				c.SideEffect(effect)
+				c.Forward(forward)
+				return nil
+			})
+		})
+		st := newStream()
+		if err := s.HandleStreamedOut(command("Forward", message("a")), st); err != nil {
+			t.Fatal(err)
+		}
+		out := st.responses()
+		if len(out) != 2 || out[1].GetForward().GetCommandName() != "Handle" || len(out[1].GetSideEffects()) != 1 {
+			t.Fatalf("responses: %v; want the forward with the side effect sent last", out)
+		}
+	})
+
+	t.Run("a reply of the context is sent last", func(t *testing.T) {
+		s := newTestServer(t, func() EntityHandler {
+			return streamedFunc(func(ctx context.Context, c *Context, name string, in <-chan proto.Message, out *Emitter) error {
+				n := 0
+				for range in {
+					n++
+				}
+				c.RespondWith(encoding.String(fmt.Sprintf("%d", n)))
+				return nil
+			})
+		})
+		st := newStream(command("Count", nil), command("", message("a")), command("", message("b")))
+		close(st.in)
+		if err := s.HandleStreamed(st); err != nil {
+			t.Fatal(err)
+		}
+		if got := st.replies(); len(got) != 1 || got[0] != "2" {
+			t.Fatalf("replies: %v; want: %v", got, []string{"2"})
+		}
+	})
+}
+
+func TestCancellation(t *testing.T) {
+	cancelled := func(calls *int) CancelFunc {
+		return func(c *Context) error {
+			*calls++
+			c.SideEffect(effect)
+			c.RespondWith(encoding.String("not sent"))
+			return nil
+		}
+	}
+	check := func(t *testing.T, st *stream, calls int) {
+		t.Helper()
+		if calls != 1 {
+			t.Fatalf("cancellation calls: %v; want: %v", calls, 1)
+		}
+		out := st.responses()
+		if len(out) != 1 || len(out[0].GetSideEffects()) != 1 || out[0].GetReply() != nil {
+			t.Fatalf("responses: %v; want one with the side effect only", out)
+		}
+	}
+
+	t.Run("a streamed out handler", func(t *testing.T) {
+		var calls int
+		s := newTestServer(t, func() EntityHandler {
+			return streamedOutFunc(func(ctx context.Context, c *Context, name string, msg proto.Message, out *Emitter) error {
+				c.CancellationFunc(cancelled(&calls))
+				<-ctx.Done()
+				return ctx.Err()
+			})
+		})
+		st := newStream()
+		st.cancel()
+		if err := s.HandleStreamedOut(command("Watch", message("a")), st); !errors.Is(err, context.Canceled) {
+			t.Fatalf("err: %v; want: %v", err, context.Canceled)
+		}
+		check(t, st, calls)
+	})
+
+	t.Run("a streamed handler", func(t *testing.T) {
+		var calls int
+		s := newTestServer(t, func() EntityHandler {
+			return streamedFunc(func(ctx context.Context, c *Context, name string, in <-chan proto.Message, out *Emitter) error {
+				c.CancellationFunc(cancelled(&calls))
+				<-ctx.Done()
+				return ctx.Err()
+			})
+		})
+		st := newStream(command("Chat", nil))
+		go st.cancel()
+		if err := s.HandleStreamed(st); !errors.Is(err, context.Canceled) {
+			t.Fatalf("err: %v; want: %v", err, context.Canceled)
+		}
+		check(t, st, calls)
+	})
+
+	t.Run("a streamed in command", func(t *testing.T) {
+		var calls int
+		s := newTestServer(t, func() EntityHandler {
+			return handlerFunc(func(c *Context, name string, msg proto.Message) error {
+				c.CancellationFunc(cancelled(&calls))
+				return nil
+			})
+		})
+		st := newStream(command("Collect", nil), command("Collect", message("a")))
+		go func() {
+			for len(st.in) > 0 {
+				time.Sleep(time.Millisecond)
+			}
+			st.cancel()
+		}()
+		if err := s.HandleStreamedIn(st); !errors.Is(err, context.Canceled) {
+			t.Fatalf("err: %v; want: %v", err, context.Canceled)
+		}
+		check(t, st, calls)
+	})
+}
+
+type handlerFunc func(c *Context, name string, msg proto.Message) error
+
+func (f handlerFunc) HandleCommand(c *Context, name string, msg proto.Message) error {
+	return f(c, name, msg)
+}
@@ -0,0 +1,200 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package subscriber implements an event router for actions subscribed to
+// event logs or topics.
+//
+// A subscriber method often receives events of many types. A Router
+// dispatches them by their CloudEvent type, the ce-type metadata, or by the
+// type URL of their payload to handlers registered for the type.
+package subscriber
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cloudstateio/go-support/cloudstate/action"
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// A HandlerFunc handles an event routed to it.
+type HandlerFunc func(ctx *action.Context, event proto.Message) error
+
+// Policy defines how events without a handler are handled.
+type Policy int
+
+const (
+	// Ignore acknowledges unknown events without a reply.
+	Ignore Policy = iota
+	// Fail fails unknown events with a client error.
+	Fail
+	// DeadLetter emits unknown events as a side effect to a dead letter
+	// command, set by WithDeadLetter.
+	DeadLetter
+)
+
+// A Router routes events to handlers by their type. It implements
+// action.EntityHandler for unary subscriber methods.
+//
+// Handlers have to be registered before the router handles events.
+type Router struct {
+	handlers map[string]HandlerFunc
+	policy   Policy
+	// deadLetterService and deadLetterCommand are the target of the side
+	// effect emitted for unknown events with the DeadLetter policy.
+	deadLetterService string
+	deadLetterCommand string
+}
+
+// Option configures a Router.
+type Option func(r *Router)
+
+// WithPolicy sets the policy for unknown events. It defaults to Ignore. The
+// DeadLetter policy is set by WithDeadLetter, as it needs a target.
+func WithPolicy(p Policy) Option {
+	return func(r *Router) {
+		r.policy = p
+	}
+}
+
+// WithDeadLetter sets the DeadLetter policy for unknown events. Unknown
+// events are emitted as a side effect to the command of the service given.
+// The side effect carries the metadata of the event, including its
+// CloudEvents attributes, so the dead letter command gets the type, source
+// and subject of the event it was not handled for.
+func WithDeadLetter(serviceName, commandName string) Option {
+	return func(r *Router) {
+		r.policy = DeadLetter
+		r.deadLetterService = serviceName
+		r.deadLetterCommand = commandName
+	}
+}
+
+// NewRouter returns a Router without handlers. An error is returned for the
+// DeadLetter policy without a target.
+func NewRouter(options ...Option) (*Router, error) {
+	r := &Router{handlers: make(map[string]HandlerFunc)}
+	for _, opt := range options {
+		opt(r)
+	}
+	if r.policy == DeadLetter && (r.deadLetterService == "" || r.deadLetterCommand == "") {
+		return nil, errors.New("the DeadLetter policy needs a service and command, set by WithDeadLetter")
+	}
+	return r, nil
+}
+
+// Handle registers a handler for the event type. The event type is either
+// a CloudEvent type or a type URL.
+func (r *Router) Handle(eventType string, h HandlerFunc) {
+	r.handlers[eventType] = h
+}
+
+// HandleProto registers a handler for events of the protobuf message type
+// T, routed by their type URL.
+func HandleProto[T proto.Message](r *Router, h func(ctx *action.Context, event T) error) {
+	var zero T
+	r.Handle(encoding.ProtoAnyBase+"/"+proto.MessageName(zero), func(ctx *action.Context, event proto.Message) error {
+		e, ok := event.(T)
+		if !ok {
+			return protocol.ClientError{Err: fmt.Errorf("event is not of type %T but: %T", zero, event)}
+		}
+		return h(ctx, e)
+	})
+}
+
+// HandleJSON registers a handler for JSON events of the event type. The
+// events are unmarshalled into a value of type T.
+func HandleJSON[T interface{}](r *Router, eventType string, h func(ctx *action.Context, event *T) error) {
+	r.Handle(eventType, func(ctx *action.Context, event proto.Message) error {
+		a, ok := event.(*any.Any)
+		if !ok {
+			return protocol.ClientError{Err: fmt.Errorf("event is not a JSON event but: %T", event)}
+		}
+		var e T
+		if err := encoding.UnmarshalJSON(a, &e); err != nil {
+			return protocol.ClientError{Err: fmt.Errorf("unable to unmarshal the JSON event: %w", err)}
+		}
+		return h(ctx, &e)
+	})
+}
+
+// HandleCommand routes an event to its handler. The CloudEvent type of
+// the event takes precedence over the type URL of its payload.
+func (r *Router) HandleCommand(ctx *action.Context, name string, msg proto.Message) error {
+	if eventType := ctx.Metadata().CloudEvent().Type(); eventType != "" {
+		if h, ok := r.handlers[eventType]; ok {
+			return h(ctx, msg)
+		}
+	}
+	if h, ok := r.handlers[typeURL(msg)]; ok {
+		return h(ctx, msg)
+	}
+	return r.unknown(ctx, msg)
+}
+
+func (r *Router) unknown(ctx *action.Context, msg proto.Message) error {
+	switch r.policy {
+	case Fail:
+		return protocol.ClientError{Err: fmt.Errorf("no handler for event of type: %q", eventTypeOf(ctx, msg))}
+	case DeadLetter:
+		payload, ok := msg.(*any.Any)
+		if !ok {
+			var err error
+			if payload, err = encoding.MarshalAny(msg); err != nil {
+				return err
+			}
+		}
+		ctx.SideEffect(&protocol.SideEffect{
+			ServiceName: r.deadLetterService,
+			CommandName: r.deadLetterCommand,
+			Payload:     payload,
+			Metadata:    ctx.Metadata(),
+		})
+		return nil
+	default:
+		return nil
+	}
+}
+
+// typeURL returns the type URL of an event. JSON events are passed as
+// they are received and carry their own type URL.
+func typeURL(msg proto.Message) string {
+	if a, ok := msg.(*any.Any); ok {
+		return a.GetTypeUrl()
+	}
+	return encoding.ProtoAnyBase + "/" + proto.MessageName(msg)
+}
+
+func eventTypeOf(ctx *action.Context, msg proto.Message) string {
+	if eventType := ctx.Metadata().CloudEvent().Type(); eventType != "" {
+		return eventType
+	}
+	return typeURL(msg)
+}
+
+// Entity returns an action entity for the service that routes its events
+// by the router. The router is used as a singleton.
+func Entity(serviceName action.ServiceName, r *Router) *action.Entity {
+	return &action.Entity{
+		ServiceName: serviceName,
+		EntityFunc: func() action.EntityHandler {
+			return r
+		},
+		Singleton: true,
+	}
+}
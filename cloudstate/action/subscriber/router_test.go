@@ -0,0 +1,139 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subscriber
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/action"
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+)
+
+type userCreated struct {
+	Name string `json:"name"`
+}
+
+func handle(t *testing.T, r *Router, md *protocol.Metadata, event *entity.ActionCommand) *entity.ActionResponse {
+	t.Helper()
+	s := action.NewServer()
+	if err := s.Register(Entity("subscriber", r)); err != nil {
+		t.Fatal(err)
+	}
+	event.ServiceName = "subscriber"
+	event.Name = "ProcessEvent"
+	event.Metadata = md
+	resp, err := s.HandleUnary(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func newRouter(t *testing.T, options ...Option) *Router {
+	t.Helper()
+	r, err := NewRouter(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRouter(t *testing.T) {
+	created, err := encoding.JSON(userCreated{Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should route by the CloudEvent type", func(t *testing.T) {
+		r := newRouter(t)
+		var got string
+		HandleJSON(r, "user.created", func(ctx *action.Context, event *userCreated) error {
+			got = event.Name
+			return nil
+		})
+		md := protocol.NewCloudEvent("1", "/users", "user.created").Metadata
+		handle(t, r, md, &entity.ActionCommand{Payload: created})
+		if got != "alice" {
+			t.Fatalf("got: %q; want: %q", got, "alice")
+		}
+	})
+
+	t.Run("should route by the type URL", func(t *testing.T) {
+		r := newRouter(t)
+		var got string
+		HandleJSON(r, created.GetTypeUrl(), func(ctx *action.Context, event *userCreated) error {
+			got = event.Name
+			return nil
+		})
+		handle(t, r, nil, &entity.ActionCommand{Payload: created})
+		if got != "alice" {
+			t.Fatalf("got: %q; want: %q", got, "alice")
+		}
+	})
+
+	t.Run("should route protobuf events", func(t *testing.T) {
+		r := newRouter(t)
+		var got string
+		HandleProto(r, func(ctx *action.Context, event *protocol.Forward) error {
+			got = event.GetServiceName()
+			return nil
+		})
+		payload, err := encoding.MarshalAny(&protocol.Forward{ServiceName: "other"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		handle(t, r, nil, &entity.ActionCommand{Payload: payload})
+		if got != "other" {
+			t.Fatalf("got: %q; want: %q", got, "other")
+		}
+	})
+
+	t.Run("should ignore unknown events", func(t *testing.T) {
+		resp := handle(t, newRouter(t), nil, &entity.ActionCommand{Payload: created})
+		if resp.GetResponse() != nil || len(resp.GetSideEffects()) > 0 {
+			t.Fatalf("resp: %v; want an empty response", resp)
+		}
+	})
+
+	t.Run("should fail unknown events", func(t *testing.T) {
+		resp := handle(t, newRouter(t, WithPolicy(Fail)), nil, &entity.ActionCommand{Payload: created})
+		if resp.GetFailure() == nil {
+			t.Fatalf("resp: %v; want a failure", resp)
+		}
+	})
+
+	t.Run("should dead letter unknown events", func(t *testing.T) {
+		r := newRouter(t, WithDeadLetter("deadletters", "Store"))
+		md := protocol.NewCloudEvent("1", "/users", "user.deleted").Metadata
+		resp := handle(t, r, md, &entity.ActionCommand{Payload: created})
+		effects := resp.GetSideEffects()
+		if len(effects) != 1 || effects[0].GetCommandName() != "Store" || effects[0].GetPayload() != created {
+			t.Fatalf("side effects: %v; want a dead letter", effects)
+		}
+		if got := effects[0].GetMetadata().CloudEvent().Type(); got != "user.deleted" {
+			t.Fatalf("dead letter type: %q; want: %q", got, "user.deleted")
+		}
+	})
+
+	t.Run("should reject the dead letter policy without a target", func(t *testing.T) {
+		if _, err := NewRouter(WithPolicy(DeadLetter)); err == nil {
+			t.Fatal("NewRouter() should fail without a dead letter target")
+		}
+	})
+}
@@ -85,7 +85,8 @@ func (cs *CloudState) RegisterCRDT(entity *crdt.Entity, config protocol.Descript
 }
 
 // RegisterAction registers an action entity.
-func (cs *CloudState) RegisterAction(entity *action.Entity, config protocol.DescriptorConfig) error {
+func (cs *CloudState) RegisterAction(entity *action.Entity, config protocol.DescriptorConfig, options ...action.Option) error {
+	entity.Options(options...)
 	if err := cs.actionServer.Register(entity); err != nil {
 		return err
 	}
@@ -107,6 +108,30 @@ func (cs *CloudState) RegisterValueEntity(entity *value.Entity, config protocol.
 	return nil
 }
 
+// Intercept adds interceptors for the commands of all entities. They run
+// before the interceptors of an entity.
+func (cs *CloudState) Intercept(interceptors ...protocol.Interceptor) {
+	cs.eventSourcedServer.Intercept(interceptors...)
+	cs.crdtServer.Intercept(interceptors...)
+	cs.actionServer.Intercept(interceptors...)
+	cs.valueServer.Intercept(interceptors...)
+}
+
+// ValidateTargets validates the targets of forwards and side effects of all
+// entities against the registered service descriptors. Invalid targets fail
+// the command with a client error. In strict mode, which is meant for tests,
+// they end the entities stream with an error instead.
+func (cs *CloudState) ValidateTargets(strict bool) {
+	v := &protocol.TargetValidation{
+		Validate: cs.entityDiscoveryServer.ValidateTarget,
+		Strict:   strict,
+	}
+	cs.eventSourcedServer.ValidateTargets(v)
+	cs.crdtServer.ValidateTargets(v)
+	cs.actionServer.ValidateTargets(v)
+	cs.valueServer.ValidateTargets(v)
+}
+
 // Run runs the CloudState instance on the interface and port defined by
 // the HOST and PORT environment variable.
 func (cs *CloudState) Run() error {
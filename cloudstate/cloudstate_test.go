@@ -24,8 +24,12 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/cloudstateio/go-support/cloudstate/action"
 	"github.com/cloudstateio/go-support/cloudstate/discovery"
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
 	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/proto"
+	filedescr "github.com/golang/protobuf/protoc-gen-go/descriptor"
 	_ "google.golang.org/genproto/googleapis/api/annotations"
 )
 
@@ -96,3 +100,98 @@ func TestEntityDiscoveryResponderReportError(t *testing.T) {
 		t.Errorf("'unable to do XYZ' not found in output: %s", output)
 	}
 }
+
+// methodEventing returns the eventing discovered for a method of the
+// EntityDiscovery service.
+func methodEventing(t *testing.T, server *discovery.EntityDiscoveryServer, method string) *Eventing {
+	t.Helper()
+	spec, err := server.Discover(context.Background(), &protocol.ProxyInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &filedescr.FileDescriptorSet{}
+	if err := proto.Unmarshal(spec.GetProto(), set); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range set.GetFile() {
+		for _, s := range f.GetService() {
+			for _, m := range s.GetMethod() {
+				if s.GetName() == "EntityDiscovery" && m.GetName() == method {
+					ext, err := proto.GetExtension(m.GetOptions(), E_Eventing)
+					if err != nil {
+						return nil
+					}
+					return ext.(*Eventing)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func TestEntityDiscoveryEventing(t *testing.T) {
+	server := discovery.NewServer(protocol.Config{ServiceName: "service.one"})
+	config := protocol.DescriptorConfig{Service: "entity.proto"}.AddEventing("discover", &Eventing{
+		In:  EventLogSource("shopping-cart"),
+		Out: TopicDestination("carts"),
+	})
+	err := server.RegisterActionEntity(&action.Entity{ServiceName: "cloudstate.EntityDiscovery"}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventing := methodEventing(t, server, "discover")
+	if eventing.GetIn().GetEventLog() != "shopping-cart" || eventing.GetOut().GetTopic() != "carts" {
+		t.Fatalf("eventing: %v; want the configured eventing", eventing)
+	}
+
+	err = server.RegisterActionEntity(&action.Entity{ServiceName: "cloudstate.EntityDiscovery"},
+		protocol.DescriptorConfig{Service: "entity.proto"}.AddEventing("unknown", &Eventing{}),
+	)
+	if err == nil {
+		t.Fatal("registering eventing for an unknown method should fail")
+	}
+}
+
+func TestEntityDiscoveryEventingChecked(t *testing.T) {
+	server := discovery.NewServer(protocol.Config{ServiceName: "service.one"})
+	config := protocol.DescriptorConfig{Service: "entity.proto"}.
+		AddEventing("discover", &Eventing{In: TopicSource("carts")}).
+		AddEventing("reportError", &protocol.Metadata{})
+	err := server.RegisterActionEntity(&action.Entity{ServiceName: "cloudstate.EntityDiscovery"}, config)
+	if err == nil {
+		t.Fatal("registering eventing of another type should fail")
+	}
+	err = server.RegisterActionEntity(&action.Entity{ServiceName: "cloudstate.EntityDiscovery"},
+		protocol.DescriptorConfig{Service: "entity.proto"}.AddEventing("reportError", &Eventing{In: TopicSource("errors")}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eventing := methodEventing(t, server, "discover"); eventing != nil {
+		t.Fatalf("eventing: %v; want none for a failed registration", eventing)
+	}
+}
+
+func TestEntityDiscoveryValidateTarget(t *testing.T) {
+	server := discovery.NewServer(protocol.Config{ServiceName: "service.one"})
+	err := server.RegisterActionEntity(&action.Entity{ServiceName: "cloudstate.EntityDiscovery"}, protocol.DescriptorConfig{Service: "entity.proto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := encoding.MarshalAny(&protocol.ProxyInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.ValidateTarget("cloudstate.EntityDiscovery", "discover", info); err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []struct{ service, command string }{
+		{"cloudstate.Unknown", "discover"},
+		{"cloudstate.EntityDiscovery", "unknown"},
+		{"cloudstate.EntityDiscovery", "reportError"},
+	} {
+		if err := server.ValidateTarget(target.service, target.command, info); err == nil {
+			t.Fatalf("ValidateTarget(%q, %q) should have failed", target.service, target.command)
+		}
+	}
+}
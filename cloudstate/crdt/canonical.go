@@ -0,0 +1,176 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	protov1 "github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// A KeyCanonicalizer returns the canonical encoding of a key. Keys of
+// ORSet, GSet and ORMap instances with a canonicalizer set are compared by
+// their canonical encoding, so that semantically equal keys with different
+// encodings are the same key. The keys themselves are kept and sent in the
+// encoding they were added or received with, as the proxy compares them by
+// their bytes. Keys the canonicalizer fails for are compared as they are
+// and the failure is logged.
+type KeyCanonicalizer func(key *any.Any) (*any.Any, error)
+
+var (
+	// PrimitiveKeys canonicalizes Cloudstate primitive keys. Other keys are
+	// returned unchanged.
+	PrimitiveKeys KeyCanonicalizer = canonicalPrimitive
+	// ProtoKeys canonicalizes keys of protobuf message types known to the
+	// global registry by re-encoding them deterministically without unknown
+	// fields. Other keys are returned unchanged.
+	ProtoKeys KeyCanonicalizer = canonicalProto
+	// JSONKeys canonicalizes Cloudstate JSON keys by re-encoding them with
+	// object keys sorted. Other keys are returned unchanged.
+	JSONKeys KeyCanonicalizer = canonicalJSON
+	// CanonicalKeys canonicalizes primitive, JSON and protobuf keys, each by
+	// their own canonicalizer.
+	CanonicalKeys KeyCanonicalizer = canonicalAny
+)
+
+func canonicalAny(key *any.Any) (*any.Any, error) {
+	switch {
+	case strings.HasPrefix(key.GetTypeUrl(), encoding.PrimitiveTypeURLPrefix+"/"):
+		return canonicalPrimitive(key)
+	case strings.HasPrefix(key.GetTypeUrl(), encoding.JSONTypeURLPrefix+"/"):
+		return canonicalJSON(key)
+	default:
+		return canonicalProto(key)
+	}
+}
+
+func canonicalPrimitive(key *any.Any) (*any.Any, error) {
+	if !strings.HasPrefix(key.GetTypeUrl(), encoding.PrimitiveTypeURLPrefix+"/") {
+		return key, nil
+	}
+	v, err := encoding.UnmarshalPrimitive(key)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return key, nil
+	}
+	return encoding.MarshalPrimitive(v)
+}
+
+func canonicalJSON(key *any.Any) (*any.Any, error) {
+	if !strings.HasPrefix(key.GetTypeUrl(), encoding.JSONTypeURLPrefix+"/") {
+		return key, nil
+	}
+	var raw json.RawMessage
+	if err := encoding.UnmarshalJSON(key, &raw); err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return key, nil
+	}
+	// encoding/json marshals map keys sorted.
+	c, err := encoding.MarshalJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	c.TypeUrl = key.GetTypeUrl()
+	return c, nil
+}
+
+func canonicalProto(key *any.Any) (*any.Any, error) {
+	mt, err := protoregistry.GlobalTypes.FindMessageByURL(key.GetTypeUrl())
+	if err != nil {
+		return key, nil
+	}
+	m := mt.New().Interface()
+	if err := proto.Unmarshal(key.GetValue(), m); err != nil {
+		return nil, err
+	}
+	protov1.DiscardUnknown(protov1.MessageV1(m))
+	value, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return &any.Any{TypeUrl: key.GetTypeUrl(), Value: value}, nil
+}
+
+// keyCanonicalized is implemented by CRDTs that hold keys.
+type keyCanonicalized interface {
+	SetKeyCanonicalizer(c KeyCanonicalizer)
+}
+
+// rehash returns values keyed by the hash of their canonical key. Values
+// with equal canonical keys collapse into one.
+func (h *anyHasher) rehash(values map[uint64]*any.Any) map[uint64]*any.Any {
+	rehashed := make(map[uint64]*any.Any, len(values))
+	for _, v := range values {
+		rehashed[h.hashAny(v)] = v
+	}
+	return rehashed
+}
+
+// SetKeyCanonicalizer sets the canonicalizer for the elements of the set.
+// Elements already added are rehashed.
+func (s *ORSet) SetKeyCanonicalizer(c KeyCanonicalizer) {
+	s.canonicalize = c
+	s.value = s.rehash(s.value)
+	s.size = sizeOf(s.value)
+	s.added = s.rehash(s.added)
+	s.removed = s.rehash(s.removed)
+}
+
+// SetKeyCanonicalizer sets the canonicalizer for the elements of the set.
+// Elements already added are rehashed.
+func (s *GSet) SetKeyCanonicalizer(c KeyCanonicalizer) {
+	s.canonicalize = c
+	s.value = s.rehash(s.value)
+	s.size = sizeOf(s.value)
+	s.added = s.rehash(s.added)
+}
+
+// SetKeyCanonicalizer sets the canonicalizer for the keys of the map and,
+// recursively, for its values and values set later. Keys already set are
+// rehashed.
+func (m *ORMap) SetKeyCanonicalizer(c KeyCanonicalizer) {
+	m.canonicalize = c
+	value := make(map[uint64]*ORMapEntry, len(m.value))
+	for _, e := range m.value {
+		if v, ok := e.Value.(keyCanonicalized); ok {
+			v.SetKeyCanonicalizer(c)
+		}
+		value[m.hashAny(e.Key)] = e
+	}
+	m.value = value
+	m.size = 0
+	for _, e := range m.value {
+		m.size += proto.Size(e.Key)
+	}
+	m.delta.added = m.rehash(m.delta.added)
+	m.delta.removed = m.rehash(m.delta.removed)
+}
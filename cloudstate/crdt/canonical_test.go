@@ -0,0 +1,204 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+)
+
+func jsonKey(t *testing.T, s string) *any.Any {
+	t.Helper()
+	a, err := encoding.MarshalJSON(jsonKeyRaw(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.TypeUrl = encoding.JSONTypeURLPrefix + "/key"
+	return a
+}
+
+type jsonKeyRaw string
+
+func (r jsonKeyRaw) MarshalJSON() ([]byte, error) {
+	return []byte(r), nil
+}
+
+func TestKeyCanonicalizer(t *testing.T) {
+	t.Run("JSON keys with reordered fields are equal", func(t *testing.T) {
+		m := NewORMap()
+		m.SetKeyCanonicalizer(JSONKeys)
+		m.Set(jsonKey(t, `{"a":1,"b":2}`), NewGCounter())
+		if !m.HasKey(jsonKey(t, `{"b":2,"a":1}`)) {
+			t.Fatal("m has no key with reordered fields")
+		}
+		m.Set(jsonKey(t, `{"b":2, "a":1}`), NewGCounter())
+		if s := m.Size(); s != 1 {
+			t.Fatalf("m.Size(): %v; want: %v", s, 1)
+		}
+	})
+
+	t.Run("proto keys with unknown fields are equal", func(t *testing.T) {
+		key, err := encoding.MarshalAny(&wrappers.StringValue{Value: "k"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		unknown := proto.Clone(key).(*any.Any)
+		unknown.Value = append(unknown.Value, 0x10, 0x01) // field 2, varint 1
+		s := NewORSet()
+		s.SetKeyCanonicalizer(CanonicalKeys)
+		s.Add(key)
+		s.Add(unknown)
+		if size := s.Size(); size != 1 {
+			t.Fatalf("s.Size(): %v; want: %v", size, 1)
+		}
+		s.Remove(unknown)
+		if size := s.Size(); size != 0 {
+			t.Fatalf("s.Size(): %v; want: %v", size, 0)
+		}
+	})
+
+	t.Run("keys are sent as they were added", func(t *testing.T) {
+		s := NewGSet()
+		s.SetKeyCanonicalizer(CanonicalKeys)
+		key := jsonKey(t, `{"b":2, "a":1}`)
+		s.Add(key)
+		added := encDecDelta(s.Delta()).GetGset().GetAdded()
+		if l := len(added); l != 1 {
+			t.Fatalf("len(added): %v; want: %v", l, 1)
+		}
+		if !proto.Equal(added[0], key) {
+			t.Fatalf("added[0]: %v; want: %v", added[0], key)
+		}
+	})
+
+	t.Run("received keys are removed as they were received", func(t *testing.T) {
+		received := jsonKey(t, `{"b":2, "a":1}`)
+		s := NewORSet()
+		s.SetKeyCanonicalizer(CanonicalKeys)
+		if err := s.applyDelta(&entity.CrdtDelta{Delta: &entity.CrdtDelta_Orset{Orset: &entity.ORSetDelta{
+			Added: []*any.Any{received, encoding.String("other")},
+		}}}); err != nil {
+			t.Fatal(err)
+		}
+		s.Remove(jsonKey(t, `{"a":1,"b":2}`))
+		removed := encDecDelta(s.Delta()).GetOrset().GetRemoved()
+		if len(removed) != 1 || !proto.Equal(removed[0], received) {
+			t.Fatalf("removed: %v; want: %v", removed, received)
+		}
+
+		m := NewORMap()
+		m.SetKeyCanonicalizer(CanonicalKeys)
+		if err := m.applyDelta(&entity.CrdtDelta{Delta: &entity.CrdtDelta_Ormap{Ormap: &entity.ORMapDelta{
+			Added: []*entity.ORMapEntryDelta{
+				{Key: received, Delta: NewFlag().Delta()},
+				{Key: encoding.String("other"), Delta: NewFlag().Delta()},
+			},
+		}}}); err != nil {
+			t.Fatal(err)
+		}
+		m.Delete(jsonKey(t, `{"a":1,"b":2}`))
+		removed = encDecDelta(m.Delta()).GetOrmap().GetRemoved()
+		if len(removed) != 1 || !proto.Equal(removed[0], received) {
+			t.Fatalf("removed: %v; want: %v", removed, received)
+		}
+	})
+
+	t.Run("keys a canonicalizer fails for are used as they are", func(t *testing.T) {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		defer log.SetOutput(os.Stderr)
+		s := NewORSet()
+		s.SetKeyCanonicalizer(func(key *any.Any) (*any.Any, error) {
+			return nil, errors.New("broken")
+		})
+		s.Add(encoding.String("k"))
+		s.Add(encoding.String("k"))
+		if s.Size() != 1 || !strings.Contains(buf.String(), "broken") {
+			t.Fatalf("s.Size(): %v, log: %q; want one key and the error logged", s.Size(), buf.String())
+		}
+	})
+
+	t.Run("existing keys are rehashed", func(t *testing.T) {
+		s := NewORSet()
+		s.Add(jsonKey(t, `{"a":1,"b":2}`))
+		s.Add(jsonKey(t, `{"b":2,"a":1}`))
+		if size := s.Size(); size != 2 {
+			t.Fatalf("s.Size(): %v; want: %v", size, 2)
+		}
+		s.SetKeyCanonicalizer(JSONKeys)
+		if size := s.Size(); size != 1 {
+			t.Fatalf("s.Size(): %v; want: %v", size, 1)
+		}
+	})
+
+	t.Run("nested values of a map get the canonicalizer", func(t *testing.T) {
+		m := NewORMap()
+		m.SetKeyCanonicalizer(JSONKeys)
+		s := NewORSet()
+		m.Set(encoding.String("s"), s)
+		s.Add(jsonKey(t, `{"a":1,"b":2}`))
+		s.Add(jsonKey(t, `{"b":2,"a":1}`))
+		if size := s.Size(); size != 1 {
+			t.Fatalf("s.Size(): %v; want: %v", size, 1)
+		}
+	})
+
+	t.Run("an entity sets the canonicalizer on incoming state", func(t *testing.T) {
+		e := &Entity{}
+		e.Options(WithKeyCanonicalizer(CanonicalKeys))
+		r := &runner{context: &Context{Entity: e}}
+		delta := &entity.CrdtDelta{Delta: &entity.CrdtDelta_Ormap{Ormap: &entity.ORMapDelta{
+			Added: []*entity.ORMapEntryDelta{{
+				Key: encoding.String("s"),
+				Delta: &entity.CrdtDelta{Delta: &entity.CrdtDelta_Orset{Orset: &entity.ORSetDelta{
+					Added: []*any.Any{jsonKey(t, `{"a":1,"b":2}`)},
+				}}},
+			}},
+		}}}
+		if err := r.handleDelta(delta); err != nil {
+			t.Fatal(err)
+		}
+		s, err := r.context.crdt.(*ORMap).ORSet(encoding.String("s"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Add(jsonKey(t, `{"b":2,"a":1}`))
+		if size := s.Size(); size != 1 {
+			t.Fatalf("s.Size(): %v; want: %v", size, 1)
+		}
+	})
+
+	t.Run("primitive keys stay as they are", func(t *testing.T) {
+		k := encoding.String("k")
+		c, err := PrimitiveKeys(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(c, k) {
+			t.Fatalf("PrimitiveKeys(): %v; want: %v", c, k)
+		}
+	})
+}
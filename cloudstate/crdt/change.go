@@ -0,0 +1,127 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// A ChangeOption configures how change notifications of a streamed command
+// are sent. Without options, every change of the CRDT is notified.
+type ChangeOption func(o *changeOptions)
+
+type changeOptions struct {
+	minInterval   time.Duration
+	dropUnchanged bool
+	batchSize     int
+	batchDelay    time.Duration
+}
+
+// WithMinInterval sets the minimum interval between two notifications.
+// Changes within the interval are coalesced into one notification sent when
+// the interval has passed.
+func WithMinInterval(d time.Duration) ChangeOption {
+	return func(o *changeOptions) {
+		o.minInterval = d
+	}
+}
+
+// WithDropUnchanged drops a notification if its reply equals the reply of
+// the previous notification and it has no side effects.
+func WithDropUnchanged() ChangeOption {
+	return func(o *changeOptions) {
+		o.dropUnchanged = true
+	}
+}
+
+// defaultBatchDelay is the maximum delay of a batch if none is given.
+const defaultBatchDelay = time.Second
+
+// WithBatch coalesces changes into one notification for every size changes,
+// or latest maxDelay after the first change of a batch. A maxDelay of zero
+// or less defaults to one second.
+func WithBatch(size int, maxDelay time.Duration) ChangeOption {
+	return func(o *changeOptions) {
+		if maxDelay <= 0 {
+			maxDelay = defaultBatchDelay
+		}
+		o.batchSize = size
+		o.batchDelay = maxDelay
+	}
+}
+
+// changeState tracks the notifications of a streamed command.
+type changeState struct {
+	options changeOptions
+	// pending is the number of changes not yet notified.
+	pending      int
+	pendingSince time.Time
+	lastSent     time.Time
+	lastReply    *any.Any
+}
+
+// changed records a change at now.
+func (s *changeState) changed(now time.Time) {
+	if s.pending == 0 {
+		s.pendingSince = now
+	}
+	s.pending++
+}
+
+// due returns the time a pending notification is due and if there is one.
+func (s *changeState) due() (time.Time, bool) {
+	if s.pending == 0 {
+		return time.Time{}, false
+	}
+	due := s.pendingSince
+	if s.options.batchSize > 0 && s.pending < s.options.batchSize {
+		due = s.pendingSince.Add(s.options.batchDelay)
+	}
+	if !s.lastSent.IsZero() {
+		if next := s.lastSent.Add(s.options.minInterval); next.After(due) {
+			due = next
+		}
+	}
+	return due, true
+}
+
+// dueAt returns true if a pending notification is due at now.
+func (s *changeState) dueAt(now time.Time) bool {
+	due, ok := s.due()
+	return ok && !due.After(now)
+}
+
+// sent records a notification with reply sent at now.
+func (s *changeState) sent(now time.Time, reply *any.Any) {
+	s.pending = 0
+	s.pendingSince = time.Time{}
+	s.lastSent = now
+	s.lastReply = reply
+}
+
+// dropped records a notification dropped as unchanged.
+func (s *changeState) dropped() {
+	s.pending = 0
+	s.pendingSince = time.Time{}
+}
+
+// unchanged returns true if reply is to be dropped as unchanged.
+func (s *changeState) unchanged(reply *any.Any) bool {
+	return s.options.dropUnchanged && s.lastReply != nil && proto.Equal(s.lastReply, reply)
+}
@@ -0,0 +1,170 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/grpc"
+)
+
+type sendStream struct {
+	grpc.ServerStream
+	sent []*entity.CrdtStreamOut
+}
+
+func (s *sendStream) Send(out *entity.CrdtStreamOut) error {
+	s.sent = append(s.sent, out)
+	return nil
+}
+
+func (s *sendStream) Recv() (*entity.CrdtStreamIn, error) {
+	panic("not implemented")
+}
+
+func (s *sendStream) Context() context.Context {
+	return context.Background()
+}
+
+// streamedValues returns the counter values sent as streamed messages.
+func (s *sendStream) streamedValues() []int64 {
+	values := make([]int64, 0)
+	for _, out := range s.sent {
+		reply := out.GetStreamedMessage().GetClientAction().GetReply()
+		if reply != nil {
+			values = append(values, encoding.DecodeInt64(reply.GetPayload()))
+		}
+	}
+	return values
+}
+
+type changeTest struct {
+	now     time.Time
+	counter *PNCounter
+	stream  *sendStream
+	runner  *runner
+}
+
+func newChangeTest(t *testing.T, options ...ChangeOption) *changeTest {
+	t.Helper()
+	ct := &changeTest{
+		now:     time.Unix(0, 0),
+		counter: NewPNCounter(),
+		stream:  &sendStream{},
+	}
+	c := &Context{
+		Entity:      &Entity{},
+		crdt:        ct.counter,
+		streamedCtx: make(map[CommandID]*CommandContext),
+	}
+	ct.runner = &runner{stream: ct.stream, context: c, clock: func() time.Time { return ct.now }}
+	ctx := c.commandContextFor(&protocol.Command{Id: 1, Streamed: true})
+	ctx.ChangeFunc(func(c *CommandContext) (*any.Any, error) {
+		return encoding.Int64(ct.counter.Value()), nil
+	}, options...)
+	ctx.trackChanges()
+	return ct
+}
+
+// change increments the counter by i and notifies the change.
+func (ct *changeTest) change(t *testing.T, i int64) {
+	t.Helper()
+	ct.counter.Increment(i)
+	ct.counter.resetDelta()
+	if err := ct.runner.handleChange(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (ct *changeTest) advance(t *testing.T, d time.Duration) {
+	t.Helper()
+	ct.now = ct.now.Add(d)
+	if err := ct.runner.flushChanges(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func equalValues(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestChangeNotifications(t *testing.T) {
+	t.Run("every change is notified without options", func(t *testing.T) {
+		ct := newChangeTest(t)
+		ct.change(t, 1)
+		ct.change(t, 1)
+		if got, want := ct.stream.streamedValues(), []int64{1, 2}; !equalValues(got, want) {
+			t.Fatalf("streamed values: %v; want: %v", got, want)
+		}
+	})
+
+	t.Run("changes within the minimum interval are coalesced", func(t *testing.T) {
+		ct := newChangeTest(t, WithMinInterval(time.Second))
+		ct.change(t, 1)
+		ct.change(t, 1)
+		ct.change(t, 1)
+		if _, ok := ct.runner.nextChange(); !ok {
+			t.Fatal("no pending change notification")
+		}
+		ct.advance(t, 500*time.Millisecond)
+		ct.advance(t, 500*time.Millisecond)
+		if got, want := ct.stream.streamedValues(), []int64{1, 3}; !equalValues(got, want) {
+			t.Fatalf("streamed values: %v; want: %v", got, want)
+		}
+		if _, ok := ct.runner.nextChange(); ok {
+			t.Fatal("unexpected pending change notification")
+		}
+	})
+
+	t.Run("unchanged replies are dropped", func(t *testing.T) {
+		ct := newChangeTest(t, WithDropUnchanged())
+		ct.change(t, 1)
+		ct.change(t, 0)
+		ct.change(t, 1)
+		if got, want := ct.stream.streamedValues(), []int64{1, 2}; !equalValues(got, want) {
+			t.Fatalf("streamed values: %v; want: %v", got, want)
+		}
+	})
+
+	t.Run("changes are batched by size and delay", func(t *testing.T) {
+		ct := newChangeTest(t, WithBatch(3, time.Second))
+		ct.change(t, 1)
+		ct.change(t, 1)
+		ct.change(t, 1)
+		ct.change(t, 1)
+		if got, want := ct.stream.streamedValues(), []int64{3}; !equalValues(got, want) {
+			t.Fatalf("streamed values: %v; want: %v", got, want)
+		}
+		ct.advance(t, time.Second)
+		if got, want := ct.stream.streamedValues(), []int64{3, 4}; !equalValues(got, want) {
+			t.Fatalf("streamed values: %v; want: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// A ClockSource returns the clock and custom clock value an LWWRegister is
+// set with. The command context is nil for registers set outside of a
+// command.
+type ClockSource func(ctx *CommandContext) (Clock, int64, error)
+
+// HybridLogicalClock returns a clock source of a hybrid logical clock. Its
+// value is the latest of the timestamp of the command metadata entry with
+// the given key, formatted as RFC 3339 or as milliseconds since the Unix
+// epoch, the system time and the last value plus one. The clock value is in
+// microseconds and increases with every set, also for timestamps behind the
+// system time. The clock source may be shared by entities.
+func HybridLogicalClock(metadataKey string) ClockSource {
+	var mu sync.Mutex
+	var last int64
+	return func(ctx *CommandContext) (Clock, int64, error) {
+		next := time.Now().UnixNano() / int64(time.Microsecond)
+		if ctx != nil {
+			for _, e := range ctx.Command().GetMetadata().GetEntries() {
+				if e.GetKey() != metadataKey {
+					continue
+				}
+				t, err := parseTimestamp(e.GetStringValue())
+				if err != nil {
+					return Default, 0, err
+				}
+				if ts := t.UnixNano() / int64(time.Microsecond); ts > next {
+					next = ts
+				}
+				break
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if next <= last {
+			next = last + 1
+		}
+		last = next
+		return CustomAutoIncrement, next, nil
+	}
+}
+
+func parseTimestamp(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	millis, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %q", s)
+	}
+	return time.Unix(0, millis*int64(time.Millisecond)), nil
+}
+
+// FieldSequence returns a clock source of a custom clock with the value of
+// an integer field of the command message. Outside of a command, the
+// default clock is used.
+func FieldSequence(field protoreflect.Name) ClockSource {
+	return func(ctx *CommandContext) (Clock, int64, error) {
+		if ctx == nil || ctx.Message() == nil {
+			return Default, 0, nil
+		}
+		m := proto.MessageReflect(ctx.Message())
+		fd := m.Descriptor().Fields().ByName(field)
+		if fd == nil {
+			return Default, 0, fmt.Errorf("no field %q in message %s", field, m.Descriptor().FullName())
+		}
+		switch fd.Kind() {
+		case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+			protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+			return Custom, m.Get(fd).Int(), nil
+		case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+			protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+			return Custom, int64(m.Get(fd).Uint()), nil
+		default:
+			return Default, 0, fmt.Errorf("field %q of message %s is not an integer", field, m.Descriptor().FullName())
+		}
+	}
+}
+
+// clocked is implemented by CRDTs that use a clock source.
+type clocked interface {
+	setEntityContext(c *Context)
+}
+
+// clockSource returns the clock source of a register, set for the
+// register or for the entity.
+func (r *LWWRegister) clockSource() ClockSource {
+	if r.source != nil {
+		return r.source
+	}
+	if r.entityCtx != nil && r.entityCtx.Entity != nil {
+		return r.entityCtx.Entity.ClockSource
+	}
+	return nil
+}
+
+// SetClockSource sets the clock source used by Set. It overrides a clock
+// source set for the entity.
+func (r *LWWRegister) SetClockSource(source ClockSource) {
+	r.source = source
+}
+
+func (r *LWWRegister) setEntityContext(c *Context) {
+	r.entityCtx = c
+}
+
+func (m *ORMap) setEntityContext(c *Context) {
+	m.entityCtx = c
+	for _, e := range m.value {
+		if v, ok := e.Value.(clocked); ok {
+			v.setEntityContext(c)
+		}
+	}
+}
+
+func (m *LWWMap) setEntityContext(c *Context) {
+	m.m.setEntityContext(c)
+}
+
+// SetClockSource sets the clock source used by Set for all keys. It
+// overrides a clock source set for the entity.
+func (m *LWWMap) SetClockSource(source ClockSource) {
+	m.source = source
+}
+
+// clockFor returns the clock for a set of a register by its clock source,
+// or the default clock without a source.
+func clockFor(source ClockSource, entityCtx *Context) (Clock, int64, error) {
+	if source == nil {
+		return Default, 0, nil
+	}
+	var ctx *CommandContext
+	if entityCtx != nil {
+		ctx = entityCtx.current
+	}
+	return source(ctx)
+}
+
+// clockOrDefault returns the clock for a set of a register by its clock
+// source, or the default clock if there is no source or the source fails.
+// A failure is logged.
+func clockOrDefault(source ClockSource, entityCtx *Context) (Clock, int64) {
+	clock, value, err := clockFor(source, entityCtx)
+	if err != nil {
+		log.Printf("the clock source failed, the default clock is used: %v", err)
+		return Default, 0
+	}
+	return clock, value
+}
+
+// clockSource returns the clock source of a map, set for the map or for the
+// entity.
+func (m *LWWMap) clockSource() ClockSource {
+	if m.source != nil {
+		return m.source
+	}
+	if m.m.entityCtx != nil && m.m.entityCtx.Entity != nil {
+		return m.m.entityCtx.Entity.ClockSource
+	}
+	return nil
+}
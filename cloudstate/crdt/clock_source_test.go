@@ -0,0 +1,178 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+)
+
+type clockedEntity struct {
+	m *ORMap
+}
+
+func (e *clockedEntity) HandleCommand(ctx *CommandContext, name string, msg proto.Message) (*any.Any, error) {
+	r, err := e.m.GetOrCreateLWWRegister(encoding.String("r"), nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Set(encoding.String(name))
+	return encoding.Empty, nil
+}
+
+func (e *clockedEntity) Default(ctx *Context) (CRDT, error) {
+	return NewORMap(), nil
+}
+
+func (e *clockedEntity) Set(ctx *Context, state CRDT) error {
+	e.m = state.(*ORMap)
+	return nil
+}
+
+func TestClockSource(t *testing.T) {
+	t.Run("an entity clock source is used by nested registers", func(t *testing.T) {
+		e := &Entity{
+			ServiceName: "test.Clocked",
+			EntityFunc: func(id EntityID) EntityHandler {
+				return &clockedEntity{}
+			},
+		}
+		e.Options(WithClockSource(FieldSequence("value")))
+		s := NewServer()
+		if err := s.Register(e); err != nil {
+			t.Fatal(err)
+		}
+		stream := &sendStream{}
+		r := &runner{stream: stream}
+		if err := s.handleInit(&entity.CrdtInit{ServiceName: "test.Clocked", EntityId: "e"}, r); err != nil {
+			t.Fatal(err)
+		}
+		payload, err := encoding.MarshalAny(&wrappers.Int64Value{Value: 42})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.handleCommand(&protocol.Command{EntityId: "e", Id: 1, Name: "Set", Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+		added := stream.sent[0].GetReply().GetStateAction().GetUpdate().GetOrmap().GetAdded()
+		if len(added) != 1 {
+			t.Fatalf("len(added): %v; want: %v", len(added), 1)
+		}
+		d := added[0].GetDelta().GetLwwregister()
+		if d.GetClock() != entity.CrdtClock_CUSTOM || d.GetCustomClockValue() != 42 {
+			t.Fatalf("clock: %v, %v; want: %v, %v", d.GetClock(), d.GetCustomClockValue(), entity.CrdtClock_CUSTOM, 42)
+		}
+	})
+
+	t.Run("a register clock source is used by Set", func(t *testing.T) {
+		r := NewLWWRegister(nil)
+		r.SetClockSource(func(ctx *CommandContext) (Clock, int64, error) {
+			return Custom, 7, nil
+		})
+		r.Set(encoding.String("v"))
+		if d := r.Delta().GetLwwregister(); d.GetCustomClockValue() != 7 {
+			t.Fatalf("custom clock value: %v; want: %v", d.GetCustomClockValue(), 7)
+		}
+	})
+
+	t.Run("a failing clock source is logged and Set uses the default clock", func(t *testing.T) {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		defer log.SetOutput(os.Stderr)
+		r := NewLWWRegister(nil)
+		r.SetClockSource(func(ctx *CommandContext) (Clock, int64, error) {
+			return Custom, 7, errors.New("no clock")
+		})
+		r.Set(encoding.String("v"))
+		if d := r.Delta().GetLwwregister(); d.GetClock() != entity.CrdtClock_DEFAULT || d.GetCustomClockValue() != 0 {
+			t.Fatalf("clock: %v, %v; want: %v, %v", d.GetClock(), d.GetCustomClockValue(), entity.CrdtClock_DEFAULT, 0)
+		}
+		if !strings.Contains(buf.String(), "no clock") {
+			t.Fatalf("log: %q; want the clock source error", buf.String())
+		}
+	})
+
+	t.Run("a failing clock source is returned by TrySet", func(t *testing.T) {
+		source := func(ctx *CommandContext) (Clock, int64, error) {
+			return Default, 0, errors.New("no clock")
+		}
+		r := NewLWWRegister(nil)
+		r.SetClockSource(source)
+		if err := r.TrySet(encoding.String("v")); err == nil || r.Value() != nil {
+			t.Fatalf("r.TrySet(): %v, r.Value(): %v; want: an error and no value", err, r.Value())
+		}
+		m := NewLWWMap()
+		m.SetClockSource(source)
+		if err := m.TrySet(encoding.String("k"), encoding.String("v")); err == nil || m.Size() != 0 {
+			t.Fatalf("m.TrySet(): %v, m.Size(): %v; want: an error and no key", err, m.Size())
+		}
+	})
+
+	t.Run("a hybrid logical clock uses metadata timestamps ahead of the system time", func(t *testing.T) {
+		hlc := HybridLogicalClock("timestamp")
+		future := time.Now().Add(time.Hour)
+		for _, want := range []int64{future.UnixNano() / 1000, future.UnixNano()/1000 + 1} {
+			clock, value, err := hlc(withTimestamp(future.Format(time.RFC3339Nano)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if clock != CustomAutoIncrement || value != want {
+				t.Fatalf("hlc(): %v, %v; want: %v, %v", clock, value, CustomAutoIncrement, want)
+			}
+		}
+	})
+
+	t.Run("a hybrid logical clock is monotonic", func(t *testing.T) {
+		hlc := HybridLogicalClock("timestamp")
+		before := time.Now().UnixNano() / 1000
+		var last int64
+		for _, ctx := range []*CommandContext{
+			withTimestamp("1000"),
+			nil,
+			withTimestamp(time.Now().Add(time.Minute).Format(time.RFC3339Nano)),
+			withTimestamp("1000"),
+			nil,
+		} {
+			_, value, err := hlc(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value <= last || value < before {
+				t.Fatalf("hlc(): %v; want: a value after %v and the system time %v", value, last, before)
+			}
+			last = value
+		}
+	})
+}
+
+func withTimestamp(ts string) *CommandContext {
+	return &CommandContext{cmd: &protocol.Command{Metadata: &protocol.Metadata{Entries: []*protocol.MetadataEntry{{
+		Key:   "timestamp",
+		Value: &protocol.MetadataEntry_StringValue{StringValue: ts},
+	}}}}}
+}
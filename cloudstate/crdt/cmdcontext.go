@@ -16,6 +16,7 @@
 package crdt
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
@@ -38,9 +39,13 @@ type CancelFunc func(c *CommandContext) error
 type CommandContext struct {
 	*Context
 	CommandID   CommandID
+	cmdCtx      context.Context
 	change      ChangeFunc
+	changes     changeState
+	timers      []*Timer
 	cancel      CancelFunc
 	cmd         *protocol.Command
+	msg         proto.Message
 	forward     *protocol.Forward
 	sideEffects []*protocol.SideEffect
 	// ended means, we will send a streamed message where we mark the message
@@ -55,6 +60,33 @@ func (c *CommandContext) Command() *protocol.Command {
 	return c.cmd
 }
 
+// Metadata returns the metadata of the command. It is empty, not nil, for
+// a command without metadata.
+func (c *CommandContext) Metadata() *protocol.Metadata {
+	if c.cmd != nil && c.cmd.Metadata == nil {
+		c.cmd.Metadata = &protocol.Metadata{}
+	}
+	return c.cmd.GetMetadata()
+}
+
+// CommandCtx returns the context.Context of the command. While the command is
+// handled it has the deadline of the command, if any, and it is the context
+// passed on by the interceptors of the command. Afterwards, for
+// change, cancel and timer functions of a streamed command, it is the
+// context of the entities stream.
+func (c *CommandContext) CommandCtx() context.Context {
+	if c.cmdCtx == nil {
+		return c.ctx
+	}
+	return c.cmdCtx
+}
+
+// Message returns the decoded message of the command, nil before the command
+// is handled.
+func (c *CommandContext) Message() proto.Message {
+	return c.msg
+}
+
 // Streamed returns whether the command handled by the context is streamed.
 func (c *CommandContext) Streamed() bool {
 	if c.cmd == nil {
@@ -64,12 +96,17 @@ func (c *CommandContext) Streamed() bool {
 }
 
 // ChangeFunc sets the function to be called whenever the CRDT is changed.
+// Options may coalesce changes into fewer notifications.
 // For non-streamed contexts this is a `no operation`.
-func (c *CommandContext) ChangeFunc(f ChangeFunc) {
+func (c *CommandContext) ChangeFunc(f ChangeFunc, options ...ChangeOption) {
 	if !c.Streamed() {
 		return
 	}
 	c.change = f
+	c.changes = changeState{}
+	for _, opt := range options {
+		opt(&c.changes.options)
+	}
 }
 
 // CancelFunc registers an on cancel handler for this command.
@@ -100,11 +137,13 @@ func (c *CommandContext) Forward(forward *protocol.Forward) {
 	c.forward = forward
 }
 
-// SideEffect adds a side effect to being emitted after the current command successfully has completed.
-func (c *CommandContext) SideEffect(effect *protocol.SideEffect) {
-	c.sideEffects = append(c.sideEffects, effect)
+// SideEffect adds side effects to being emitted after the current command successfully has completed.
+func (c *CommandContext) SideEffect(effects ...*protocol.SideEffect) {
+	c.sideEffects = append(c.sideEffects, effects...)
 }
 
+// WriteConsistency sets the write consistency for the state action of this
+// command. It overrides any write consistency configured for the entity.
 func (c *CommandContext) WriteConsistency(wc entity.CrdtWriteConsistency) {
 	c.writeConsistency = wc
 }
@@ -113,7 +152,8 @@ func (c *CommandContext) runCommand(cmd *protocol.Command) (*any.Any, error) {
 	// unmarshal the commands message
 	msgName := strings.TrimPrefix(cmd.GetPayload().GetTypeUrl(), "type.googleapis.com/")
 	if strings.HasPrefix(msgName, "json.cloudstate.io/") {
-		return c.Instance.HandleCommand(c, cmd.Name, cmd.Payload)
+		c.msg = cmd.Payload
+		return c.handleCommand(cmd, cmd.Payload)
 	}
 	messageType := proto.MessageType(msgName)
 	message, ok := reflect.New(messageType.Elem()).Interface().(proto.Message)
@@ -123,7 +163,37 @@ func (c *CommandContext) runCommand(cmd *protocol.Command) (*any.Any, error) {
 	if err := proto.Unmarshal(cmd.Payload.Value, message); err != nil {
 		return nil, err
 	}
-	return c.Instance.HandleCommand(c, cmd.Name, message)
+	c.msg = message
+	return c.handleCommand(cmd, message)
+}
+
+// handleCommand handles a command by the entity instance, intercepted by
+// the interceptors of the entity.
+func (c *CommandContext) handleCommand(cmd *protocol.Command, msg proto.Message) (reply *any.Any, err error) {
+	ic := &protocol.InterceptedCommand{
+		Kind:        protocol.CRDT,
+		ServiceName: c.Entity.ServiceName.String(),
+		EntityID:    cmd.EntityId,
+		Name:        cmd.Name,
+		Message:     msg,
+		Metadata:    cmd.Metadata,
+	}
+	ctx, cancel := protocol.CommandContext(c.ctx, c.Entity.Timeout, cmd.Metadata)
+	defer func() {
+		cancel()
+		c.cmdCtx = nil
+	}()
+	c.cmdCtx = ctx
+	err = protocol.Intercept(ctx, ic, c.interceptors, func(ctx context.Context, ic *protocol.InterceptedCommand) error {
+		c.cmdCtx = ctx
+		var err error
+		reply, err = c.Instance.HandleCommand(c, ic.Name, ic.Message)
+		return err
+	})
+	if forward := ic.Forwarded(); forward != nil {
+		c.Forward(forward)
+	}
+	return reply, protocol.TimedOut(c.cmdCtx, err)
 }
 
 func (c *CommandContext) clientActionFor(reply *any.Any) (*protocol.ClientAction, error) {
@@ -197,10 +267,11 @@ func (c *CommandContext) cancelled() error {
 
 func (c *Context) commandContextFor(cmd *protocol.Command) *CommandContext {
 	return &CommandContext{
-		Context:     c,
-		cmd:         cmd,
-		CommandID:   CommandID(cmd.Id),
-		sideEffects: make([]*protocol.SideEffect, 0),
+		Context:          c,
+		cmd:              cmd,
+		CommandID:        CommandID(cmd.Id),
+		sideEffects:      make([]*protocol.SideEffect, 0),
+		writeConsistency: c.Entity.writeConsistencyFor(cmd.Name),
 	}
 }
 
@@ -0,0 +1,60 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+)
+
+func TestCommandContextWriteConsistency(t *testing.T) {
+	e := &Entity{}
+	e.Options(
+		WithWriteConsistency(entity.CrdtWriteConsistency_MAJORITY),
+		WithCommandWriteConsistency("Reserve", entity.CrdtWriteConsistency_ALL),
+	)
+	c := &Context{Entity: e}
+
+	t.Run("should use the entity default", func(t *testing.T) {
+		ctx := c.commandContextFor(&protocol.Command{Name: "Add"})
+		ctx.crdt = NewGCounter()
+		ctx.crdt.(*GCounter).Increment(1)
+		if wc := ctx.stateAction().GetWriteConsistency(); wc != entity.CrdtWriteConsistency_MAJORITY {
+			t.Fatalf("got: %v; want: %v", wc, entity.CrdtWriteConsistency_MAJORITY)
+		}
+	})
+
+	t.Run("should use the command override", func(t *testing.T) {
+		ctx := c.commandContextFor(&protocol.Command{Name: "Reserve"})
+		ctx.crdt = NewGCounter()
+		ctx.crdt.(*GCounter).Increment(1)
+		if wc := ctx.stateAction().GetWriteConsistency(); wc != entity.CrdtWriteConsistency_ALL {
+			t.Fatalf("got: %v; want: %v", wc, entity.CrdtWriteConsistency_ALL)
+		}
+	})
+
+	t.Run("should let a handler override the default", func(t *testing.T) {
+		ctx := c.commandContextFor(&protocol.Command{Name: "Reserve"})
+		ctx.WriteConsistency(entity.CrdtWriteConsistency_LOCAL)
+		ctx.crdt = NewGCounter()
+		ctx.crdt.(*GCounter).Increment(1)
+		if wc := ctx.stateAction().GetWriteConsistency(); wc != entity.CrdtWriteConsistency_LOCAL {
+			t.Fatalf("got: %v; want: %v", wc, entity.CrdtWriteConsistency_LOCAL)
+		}
+	})
+}
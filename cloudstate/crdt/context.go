@@ -18,6 +18,9 @@ package crdt
 import (
 	"context"
 	"errors"
+	"time"
+
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
 )
 
 // Context holds the context of a running entity.
@@ -37,6 +40,16 @@ type Context struct {
 	// created defines if the CRDT was created by the user function.
 	created bool
 	deleted bool
+	// guard enforces the entities limits, nil without limits.
+	guard *sizeGuard
+	// interceptors intercept commands of the entity.
+	interceptors []protocol.Interceptor
+	// targets validates forward and side effect targets, if set.
+	targets *protocol.TargetValidation
+	// clock returns the current time, time.Now if not set.
+	clock func() time.Time
+	// current is the context of the command currently handled.
+	current *CommandContext
 	// failed holds an internal error occurred during message processing where no error path was possible.
 	// user function Emit calls are an example.
 	failed error
@@ -80,6 +93,7 @@ func (c *Context) initDefault() error {
 	if c.crdt == nil {
 		return errors.New("no default CRDT set by the entities default method")
 	}
+	c.adopt(c.crdt)
 	// the entity gets the CRDT to be set.
 	if err := c.Instance.Set(c, c.crdt); err != nil {
 		return err
@@ -87,3 +101,35 @@ func (c *Context) initDefault() error {
 	c.created = true
 	return nil
 }
+
+// adopt sets the entities key canonicalizer and size guard, if any, and
+// the context on a CRDT.
+func (c *Context) adopt(crdt CRDT) {
+	if c.Entity == nil {
+		return
+	}
+	if k, ok := crdt.(keyCanonicalized); ok && c.Entity.KeyCanonicalizer != nil {
+		k.SetKeyCanonicalizer(c.Entity.KeyCanonicalizer)
+	}
+	if g, ok := crdt.(guarded); ok && c.guard != nil {
+		g.setSizeGuard(c.guard)
+	}
+	if k, ok := crdt.(clocked); ok {
+		k.setEntityContext(c)
+	}
+}
+
+// reportUsage reports the limit usage of the entity, if requested.
+func (c *Context) reportUsage() {
+	if c.Entity == nil || c.Entity.LimitUsageFunc == nil || c.crdt == nil {
+		return
+	}
+	c.Entity.LimitUsageFunc(c.EntityID, usageOf(c.crdt, c.Entity.Limits))
+}
+
+func (c *Context) now() time.Time {
+	if c.clock != nil {
+		return c.clock()
+	}
+	return time.Now()
+}
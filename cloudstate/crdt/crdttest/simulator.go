@@ -0,0 +1,270 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crdttest provides an in-memory simulator of CRDT replicas to test
+// entity logic built on the CRDTs of package crdt.
+package crdttest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+
+	"github.com/cloudstateio/go-support/cloudstate/crdt"
+	"github.com/cloudstateio/go-support/cloudstate/crdt/internal/crdtinternal"
+)
+
+// A Simulator runs replicas of a CRDT in memory to test that entity logic
+// built on them converges under concurrency. Each replica consists of a CRDT,
+// as a user function sees it, and of the state the proxy keeps for it. Changes
+// made to a replica are sent as messages to all other replicas, where the
+// proxy state merges them and hands the resulting delta to the replica's CRDT.
+//
+// The network between replicas may reorder and duplicate messages and may be
+// partitioned. Messages between partitioned replicas are kept until the
+// partition heals.
+type Simulator struct {
+	replicas    []*simReplica
+	queue       []*simMessage
+	partition   []int
+	rand        *rand.Rand
+	reorder     bool
+	duplication float64
+	clock       int64
+}
+
+type simReplica struct {
+	node  *simNode
+	crdt  crdt.CRDT
+	state simState
+}
+
+type simMessage struct {
+	from, to   int
+	state      simState
+	duplicated bool
+}
+
+// SimulatorOption configures a Simulator.
+type SimulatorOption func(s *Simulator)
+
+// WithSeed seeds the random source used to reorder and duplicate messages.
+func WithSeed(seed int64) SimulatorOption {
+	return func(s *Simulator) {
+		s.rand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithReordering delivers messages in random order instead of the order they
+// were sent.
+func WithReordering() SimulatorOption {
+	return func(s *Simulator) {
+		s.reorder = true
+	}
+}
+
+// WithDuplication delivers a message a second time with the given probability.
+func WithDuplication(probability float64) SimulatorOption {
+	return func(s *Simulator) {
+		s.duplication = probability
+	}
+}
+
+// NewSimulator returns a Simulator with the given number of replicas, each
+// initialized with a CRDT created by newFunc.
+func NewSimulator(replicas int, newFunc func() crdt.CRDT, options ...SimulatorOption) (*Simulator, error) {
+	if replicas < 1 {
+		return nil, errors.New("a simulator needs at least one replica")
+	}
+	s := &Simulator{
+		replicas:  make([]*simReplica, replicas),
+		partition: make([]int, replicas),
+		rand:      rand.New(rand.NewSource(1)),
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	for i := range s.replicas {
+		c := newFunc()
+		if c.HasDelta() {
+			return nil, errors.New("the CRDT created for a replica must not have a delta")
+		}
+		state, err := newSimStateFor(c)
+		if err != nil {
+			return nil, err
+		}
+		s.replicas[i] = &simReplica{
+			node:  &simNode{id: i, replicas: replicas, now: s.now},
+			crdt:  c,
+			state: state,
+		}
+	}
+	return s, nil
+}
+
+func (s *Simulator) now() int64 {
+	return s.clock
+}
+
+// Replica returns the CRDT of the i-th replica.
+func (s *Simulator) Replica(i int) crdt.CRDT {
+	return s.replicas[i].crdt
+}
+
+// Update runs a local operation on the CRDT of the i-th replica and sends its
+// delta to all other replicas.
+func (s *Simulator) Update(i int, op func(c crdt.CRDT) error) error {
+	r := s.replicas[i]
+	if err := op(r.crdt); err != nil {
+		return err
+	}
+	if !r.crdt.HasDelta() {
+		return nil
+	}
+	s.clock++
+	delta := r.crdt.Delta()
+	crdtinternal.ResetDelta(r.crdt)
+	out, err := r.state.local(r.node, delta)
+	if err != nil {
+		return err
+	}
+	for j := range s.replicas {
+		if j != i {
+			s.queue = append(s.queue, &simMessage{from: i, to: j, state: out.clone()})
+		}
+	}
+	return nil
+}
+
+// Partition splits the replicas into the given groups. Replicas not listed
+// form a group of their own. Messages are only delivered within a group.
+func (s *Simulator) Partition(groups ...[]int) {
+	for i := range s.partition {
+		s.partition[i] = len(groups) + i
+	}
+	for g, group := range groups {
+		for _, i := range group {
+			s.partition[i] = g
+		}
+	}
+}
+
+// Heal removes all partitions.
+func (s *Simulator) Heal() {
+	for i := range s.partition {
+		s.partition[i] = 0
+	}
+}
+
+// Pending returns the number of messages not yet delivered.
+func (s *Simulator) Pending() int {
+	return len(s.queue)
+}
+
+// Step delivers one message and reports if there was one to be delivered.
+func (s *Simulator) Step() (bool, error) {
+	deliverable := make([]int, 0, len(s.queue))
+	for i, m := range s.queue {
+		if s.partition[m.from] == s.partition[m.to] {
+			deliverable = append(deliverable, i)
+		}
+	}
+	if len(deliverable) == 0 {
+		return false, nil
+	}
+	i := deliverable[0]
+	if s.reorder {
+		i = deliverable[s.rand.Intn(len(deliverable))]
+	}
+	m := s.queue[i]
+	if !m.duplicated && s.duplication > 0 && s.rand.Float64() < s.duplication {
+		m.duplicated = true
+	} else {
+		s.queue = append(s.queue[:i], s.queue[i+1:]...)
+	}
+	return true, s.deliver(m)
+}
+
+// Deliver delivers all messages that can be delivered.
+func (s *Simulator) Deliver() error {
+	for {
+		delivered, err := s.Step()
+		if err != nil || !delivered {
+			return err
+		}
+	}
+}
+
+func (s *Simulator) deliver(m *simMessage) error {
+	r := s.replicas[m.to]
+	old := r.state.clone()
+	if err := r.state.merge(m.state); err != nil {
+		return err
+	}
+	delta := r.state.diff(r.node, old)
+	if delta == nil {
+		return nil
+	}
+	return crdtinternal.ApplyDelta(r.crdt, delta)
+}
+
+// Converged returns an error if the CRDTs of the replicas have different
+// values.
+func (s *Simulator) Converged() error {
+	first := simValue(s.replicas[0].crdt)
+	for i, r := range s.replicas[1:] {
+		if v := simValue(r.crdt); !reflect.DeepEqual(first, v) {
+			return fmt.Errorf("replica 0 and replica %d diverged: %v != %v", i+1, first, v)
+		}
+	}
+	return nil
+}
+
+// simValue returns a comparable value of a CRDT.
+func simValue(c crdt.CRDT) interface{} {
+	switch t := c.(type) {
+	case *crdt.Flag:
+		return t.Value()
+	case *crdt.GCounter:
+		return t.Value()
+	case *crdt.PNCounter:
+		return t.Value()
+	case *crdt.GSet:
+		return sortedKeys(t.Value())
+	case *crdt.ORSet:
+		return sortedKeys(t.Value())
+	case *crdt.LWWRegister:
+		return anyKey(t.Value())
+	case *crdt.Vote:
+		return [2]uint32{t.VotesFor(), t.Voters()}
+	case *crdt.LWWMap:
+		value := make(map[string]string, len(t.Entries()))
+		for _, e := range t.Entries() {
+			value[anyKey(e.Key)] = anyKey(e.Value)
+		}
+		return value
+	case *crdt.MVRegister:
+		return simValue(t.ORSet())
+	case *crdt.ORMap:
+		value := make(map[string]interface{}, t.Size())
+		for _, e := range t.Entries() {
+			value[anyKey(e.Key)] = simValue(e.Value)
+		}
+		return value
+	default:
+		return c.Delta().String()
+	}
+}
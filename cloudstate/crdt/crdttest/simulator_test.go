@@ -0,0 +1,252 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdttest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/crdt"
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+func TestSimulator(t *testing.T) {
+	t.Run("GCounter should converge with reordered and duplicated messages", func(t *testing.T) {
+		sim, err := NewSimulator(3, func() crdt.CRDT { return crdt.NewGCounter() }, WithReordering(), WithDuplication(0.5), WithSeed(7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			for n := 0; n < 4; n++ {
+				if err := sim.Update(i, func(c crdt.CRDT) error {
+					c.(*crdt.GCounter).Increment(uint64(i + 1))
+					return nil
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := sim.Deliver(); err != nil {
+			t.Fatal(err)
+		}
+		if err := sim.Converged(); err != nil {
+			t.Fatal(err)
+		}
+		if v := sim.Replica(2).(*crdt.GCounter).Value(); v != 24 {
+			t.Fatalf("Value(): %v; want: %v", v, 24)
+		}
+	})
+
+	t.Run("ORSet should let a concurrent add win over a remove", func(t *testing.T) {
+		sim, err := NewSimulator(2, func() crdt.CRDT { return crdt.NewORSet() }, WithReordering())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = sim.Update(0, func(c crdt.CRDT) error {
+			c.(*crdt.ORSet).Add(encoding.String("x"))
+			c.(*crdt.ORSet).Add(encoding.String("y"))
+			return nil
+		})
+		if err := sim.Deliver(); err != nil {
+			t.Fatal(err)
+		}
+		sim.Partition([]int{0}, []int{1})
+		_ = sim.Update(0, func(c crdt.CRDT) error {
+			c.(*crdt.ORSet).Remove(encoding.String("x"))
+			return nil
+		})
+		_ = sim.Update(1, func(c crdt.CRDT) error {
+			c.(*crdt.ORSet).Remove(encoding.String("x"))
+			return nil
+		})
+		_ = sim.Update(1, func(c crdt.CRDT) error {
+			c.(*crdt.ORSet).Add(encoding.String("x"))
+			return nil
+		})
+		if err := sim.Deliver(); err != nil {
+			t.Fatal(err)
+		}
+		if sim.Pending() != 3 {
+			t.Fatalf("Pending(): %v; want: %v", sim.Pending(), 3)
+		}
+		if err := sim.Converged(); err == nil {
+			t.Fatal("partitioned replicas should have diverged")
+		}
+		sim.Heal()
+		if err := sim.Deliver(); err != nil {
+			t.Fatal(err)
+		}
+		if err := sim.Converged(); err != nil {
+			t.Fatal(err)
+		}
+		values := make(map[string]bool)
+		for _, v := range sim.Replica(0).(*crdt.ORSet).Value() {
+			values[encoding.DecodeString(v)] = true
+		}
+		if !values["x"] || !values["y"] {
+			t.Fatal("the concurrently added x should have won")
+		}
+	})
+
+	t.Run("ORMap of PNCounters should converge after a partition", func(t *testing.T) {
+		sim, err := NewSimulator(3, func() crdt.CRDT { return crdt.NewORMap() }, WithReordering(), WithDuplication(0.3))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sim.Partition([]int{0, 1}, []int{2})
+		for i := 0; i < 3; i++ {
+			if err := sim.Update(i, func(c crdt.CRDT) error {
+				m := c.(*crdt.ORMap)
+				counter, err := m.PNCounter(encoding.String("stock"))
+				if err != nil {
+					return err
+				}
+				if counter == nil {
+					counter = crdt.NewPNCounter()
+					m.Set(encoding.String("stock"), counter)
+				}
+				counter.Decrement(1)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := sim.Deliver(); err != nil {
+			t.Fatal(err)
+		}
+		sim.Heal()
+		if err := sim.Deliver(); err != nil {
+			t.Fatal(err)
+		}
+		if err := sim.Converged(); err != nil {
+			t.Fatal(err)
+		}
+		counter, err := sim.Replica(2).(*crdt.ORMap).PNCounter(encoding.String("stock"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counter.Value() != -3 {
+			t.Fatalf("Value(): %v; want: %v", counter.Value(), -3)
+		}
+	})
+
+	t.Run("LWWRegister should converge to one value", func(t *testing.T) {
+		sim, err := NewSimulator(3, func() crdt.CRDT { return crdt.NewLWWRegister(nil) }, WithReordering())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range []string{"one", "two", "three"} {
+			v := v
+			_ = sim.Update(i, func(c crdt.CRDT) error {
+				c.(*crdt.LWWRegister).Set(encoding.String(v))
+				return nil
+			})
+		}
+		if err := sim.Deliver(); err != nil {
+			t.Fatal(err)
+		}
+		if err := sim.Converged(); err != nil {
+			t.Fatal(err)
+		}
+		if v := encoding.DecodeString(sim.Replica(0).(*crdt.LWWRegister).Value()); v != "three" {
+			t.Fatalf("Value(): %v; want: %v", v, "three")
+		}
+	})
+
+	t.Run("Vote should count the votes of all replicas", func(t *testing.T) {
+		sim, err := NewSimulator(3, func() crdt.CRDT { return crdt.NewVote() })
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = sim.Update(0, func(c crdt.CRDT) error {
+			c.(*crdt.Vote).Vote(true)
+			return nil
+		})
+		_ = sim.Update(2, func(c crdt.CRDT) error {
+			c.(*crdt.Vote).Vote(true)
+			return nil
+		})
+		if err := sim.Deliver(); err != nil {
+			t.Fatal(err)
+		}
+		if err := sim.Converged(); err != nil {
+			t.Fatal(err)
+		}
+		vote := sim.Replica(1).(*crdt.Vote)
+		if !vote.Majority() || vote.All() || vote.SelfVote() {
+			t.Fatalf("vote: %+v", vote)
+		}
+	})
+
+	t.Run("MVRegister should keep concurrent values until replaced", func(t *testing.T) {
+		sim, err := NewSimulator(2, func() crdt.CRDT { return crdt.NewMVRegister() })
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range []string{"a", "b"} {
+			v := v
+			_ = sim.Update(i, func(c crdt.CRDT) error {
+				c.(*crdt.MVRegister).Set(encoding.String(v))
+				return nil
+			})
+		}
+		if err := sim.Deliver(); err != nil {
+			t.Fatal(err)
+		}
+		if err := sim.Converged(); err != nil {
+			t.Fatal(err)
+		}
+		r := sim.Replica(1).(*crdt.MVRegister)
+		want := sortedKeys([]*any.Any{encoding.String("a"), encoding.String("b")})
+		if got := sortedKeys(r.Values()); !r.Conflicted() || !reflect.DeepEqual(got, want) {
+			t.Fatalf("r.Values(): %v; want: a and b", r.Values())
+		}
+		_ = sim.Update(1, func(c crdt.CRDT) error {
+			c.(*crdt.MVRegister).Set(encoding.String("c"))
+			return nil
+		})
+		if err := sim.Deliver(); err != nil {
+			t.Fatal(err)
+		}
+		if err := sim.Converged(); err != nil {
+			t.Fatal(err)
+		}
+		if v, ok := sim.Replica(0).(*crdt.MVRegister).Value(); !ok || encoding.DecodeString(v) != "c" {
+			t.Fatalf("Value(): %v, %v; want: %v", v, ok, "c")
+		}
+	})
+
+	t.Run("LWWMap should converge concurrent updates", func(t *testing.T) {
+		sim, err := NewSimulator(2, func() crdt.CRDT { return crdt.NewLWWMap() }, WithReordering())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range []string{"a", "b"} {
+			v := v
+			_ = sim.Update(i, func(c crdt.CRDT) error {
+				c.(*crdt.LWWMap).Set(encoding.String("k"), encoding.String(v))
+				return nil
+			})
+		}
+		if err := sim.Deliver(); err != nil {
+			t.Fatal(err)
+		}
+		if err := sim.Converged(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
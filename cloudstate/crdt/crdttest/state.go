@@ -0,0 +1,791 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdttest
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cloudstateio/go-support/cloudstate/crdt"
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// A simState is the state of a CRDT as a proxy replica holds it. Unlike the
+// CRDTs of this package, which only see one replica's view, simStates are
+// delta-state CRDTs; merging them is idempotent, commutative and associative,
+// so they converge regardless of how they are exchanged.
+type simState interface {
+	// local applies a delta produced by a user function on the replica of
+	// node n and returns the delta-state to be sent to other replicas.
+	local(n *simNode, delta *entity.CrdtDelta) (simState, error)
+	// merge joins another state of the same type into this one.
+	merge(other simState) error
+	// diff returns the delta that brings a user function from the old state
+	// to this one or nil if nothing has changed.
+	diff(n *simNode, old simState) *entity.CrdtDelta
+	clone() simState
+	empty() simState
+}
+
+// simNode is a replica of the simulated proxy cluster.
+type simNode struct {
+	id       int
+	seq      uint64
+	replicas int
+	now      func() int64
+}
+
+// simDot uniquely identifies an addition by a node.
+type simDot struct {
+	node int
+	seq  uint64
+}
+
+func (n *simNode) nextDot() simDot {
+	n.seq++
+	return simDot{node: n.id, seq: n.seq}
+}
+
+func newSimStateFor(c crdt.CRDT) (simState, error) {
+	switch c.(type) {
+	case *crdt.Flag:
+		return &simFlag{}, nil
+	case *crdt.GCounter:
+		return &simGCounter{counts: make(map[int]uint64)}, nil
+	case *crdt.PNCounter:
+		return &simPNCounter{p: make(map[int]uint64), n: make(map[int]uint64)}, nil
+	case *crdt.GSet:
+		return &simGSet{elems: make(map[string]*any.Any)}, nil
+	case *crdt.ORSet, *crdt.MVRegister:
+		return newSimORSet(), nil
+	case *crdt.LWWRegister:
+		return &simLWWRegister{}, nil
+	case *crdt.ORMap, *crdt.LWWMap:
+		return newSimORMap(), nil
+	case *crdt.Vote:
+		return &simVote{votes: make(map[int]simBallot)}, nil
+	default:
+		return nil, fmt.Errorf("no simulated state for CRDT type: %T", c)
+	}
+}
+
+func newSimStateForDelta(delta *entity.CrdtDelta) (simState, error) {
+	switch t := delta.GetDelta().(type) {
+	case *entity.CrdtDelta_Flag:
+		return newSimStateFor(crdt.NewFlag())
+	case *entity.CrdtDelta_Gcounter:
+		return newSimStateFor(crdt.NewGCounter())
+	case *entity.CrdtDelta_Gset:
+		return newSimStateFor(crdt.NewGSet())
+	case *entity.CrdtDelta_Lwwregister:
+		return newSimStateFor(crdt.NewLWWRegister(nil))
+	case *entity.CrdtDelta_Ormap:
+		return newSimStateFor(crdt.NewORMap())
+	case *entity.CrdtDelta_Orset:
+		return newSimStateFor(crdt.NewORSet())
+	case *entity.CrdtDelta_Pncounter:
+		return newSimStateFor(crdt.NewPNCounter())
+	case *entity.CrdtDelta_Vote:
+		return newSimStateFor(crdt.NewVote())
+	default:
+		return nil, fmt.Errorf("no CRDT type matched: %v", t)
+	}
+}
+
+// simZeroDelta returns a delta of the type of the given state that does not
+// change anything, so that an unchanged value can still be added to an ORMap.
+func simZeroDelta(s simState) *entity.CrdtDelta {
+	switch s.(type) {
+	case *simFlag:
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Flag{Flag: &entity.FlagDelta{}}}
+	case *simGCounter:
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Gcounter{Gcounter: &entity.GCounterDelta{}}}
+	case *simPNCounter:
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Pncounter{Pncounter: &entity.PNCounterDelta{}}}
+	case *simGSet:
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Gset{Gset: &entity.GSetDelta{}}}
+	case *simORSet:
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Orset{Orset: &entity.ORSetDelta{}}}
+	case *simLWWRegister:
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Lwwregister{Lwwregister: &entity.LWWRegisterDelta{}}}
+	case *simORMap:
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Ormap{Ormap: &entity.ORMapDelta{}}}
+	default:
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Vote{Vote: &entity.VoteDelta{}}}
+	}
+}
+
+// sortedKeys returns the sorted keys of the given values.
+func sortedKeys(values []*any.Any) []string {
+	keys := make([]string, len(values))
+	for i, v := range values {
+		keys[i] = anyKey(v)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// anyKey returns a comparable key for an any.Any value.
+func anyKey(a *any.Any) string {
+	if a == nil {
+		return ""
+	}
+	return a.GetTypeUrl() + "|" + string(a.GetValue())
+}
+
+type simFlag struct {
+	value bool
+}
+
+func (f *simFlag) local(_ *simNode, delta *entity.CrdtDelta) (simState, error) {
+	d := delta.GetFlag()
+	if d == nil {
+		return nil, fmt.Errorf("unable to apply delta %v to a simulated Flag", delta)
+	}
+	f.value = f.value || d.GetValue()
+	return &simFlag{value: d.GetValue()}, nil
+}
+
+func (f *simFlag) merge(other simState) error {
+	o, ok := other.(*simFlag)
+	if !ok {
+		return fmt.Errorf("unable to merge %T into a simulated Flag", other)
+	}
+	f.value = f.value || o.value
+	return nil
+}
+
+func (f *simFlag) diff(_ *simNode, old simState) *entity.CrdtDelta {
+	if f.value == old.(*simFlag).value {
+		return nil
+	}
+	return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Flag{Flag: &entity.FlagDelta{Value: f.value}}}
+}
+
+func (f *simFlag) clone() simState {
+	return &simFlag{value: f.value}
+}
+
+func (f *simFlag) empty() simState {
+	return &simFlag{}
+}
+
+type simGCounter struct {
+	counts map[int]uint64
+}
+
+func (c *simGCounter) value() (v uint64) {
+	for _, count := range c.counts {
+		v += count
+	}
+	return
+}
+
+func (c *simGCounter) local(n *simNode, delta *entity.CrdtDelta) (simState, error) {
+	d := delta.GetGcounter()
+	if d == nil {
+		return nil, fmt.Errorf("unable to apply delta %v to a simulated GCounter", delta)
+	}
+	c.counts[n.id] += d.GetIncrement()
+	return &simGCounter{counts: map[int]uint64{n.id: c.counts[n.id]}}, nil
+}
+
+func (c *simGCounter) merge(other simState) error {
+	o, ok := other.(*simGCounter)
+	if !ok {
+		return fmt.Errorf("unable to merge %T into a simulated GCounter", other)
+	}
+	for node, count := range o.counts {
+		if count > c.counts[node] {
+			c.counts[node] = count
+		}
+	}
+	return nil
+}
+
+func (c *simGCounter) diff(_ *simNode, old simState) *entity.CrdtDelta {
+	increment := c.value() - old.(*simGCounter).value()
+	if increment == 0 {
+		return nil
+	}
+	return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Gcounter{Gcounter: &entity.GCounterDelta{Increment: increment}}}
+}
+
+func (c *simGCounter) clone() simState {
+	counts := make(map[int]uint64, len(c.counts))
+	for node, count := range c.counts {
+		counts[node] = count
+	}
+	return &simGCounter{counts: counts}
+}
+
+func (c *simGCounter) empty() simState {
+	return &simGCounter{counts: make(map[int]uint64)}
+}
+
+type simPNCounter struct {
+	p map[int]uint64
+	n map[int]uint64
+}
+
+func (c *simPNCounter) value() (v int64) {
+	for _, count := range c.p {
+		v += int64(count)
+	}
+	for _, count := range c.n {
+		v -= int64(count)
+	}
+	return
+}
+
+func (c *simPNCounter) local(n *simNode, delta *entity.CrdtDelta) (simState, error) {
+	d := delta.GetPncounter()
+	if d == nil {
+		return nil, fmt.Errorf("unable to apply delta %v to a simulated PNCounter", delta)
+	}
+	if change := d.GetChange(); change > 0 {
+		c.p[n.id] += uint64(change)
+	} else {
+		c.n[n.id] += uint64(-change)
+	}
+	return &simPNCounter{
+		p: map[int]uint64{n.id: c.p[n.id]},
+		n: map[int]uint64{n.id: c.n[n.id]},
+	}, nil
+}
+
+func (c *simPNCounter) merge(other simState) error {
+	o, ok := other.(*simPNCounter)
+	if !ok {
+		return fmt.Errorf("unable to merge %T into a simulated PNCounter", other)
+	}
+	for node, count := range o.p {
+		if count > c.p[node] {
+			c.p[node] = count
+		}
+	}
+	for node, count := range o.n {
+		if count > c.n[node] {
+			c.n[node] = count
+		}
+	}
+	return nil
+}
+
+func (c *simPNCounter) diff(_ *simNode, old simState) *entity.CrdtDelta {
+	change := c.value() - old.(*simPNCounter).value()
+	if change == 0 {
+		return nil
+	}
+	return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Pncounter{Pncounter: &entity.PNCounterDelta{Change: change}}}
+}
+
+func (c *simPNCounter) clone() simState {
+	p := make(map[int]uint64, len(c.p))
+	for node, count := range c.p {
+		p[node] = count
+	}
+	n := make(map[int]uint64, len(c.n))
+	for node, count := range c.n {
+		n[node] = count
+	}
+	return &simPNCounter{p: p, n: n}
+}
+
+func (c *simPNCounter) empty() simState {
+	return &simPNCounter{p: make(map[int]uint64), n: make(map[int]uint64)}
+}
+
+type simGSet struct {
+	elems map[string]*any.Any
+}
+
+func (s *simGSet) local(_ *simNode, delta *entity.CrdtDelta) (simState, error) {
+	d := delta.GetGset()
+	if d == nil {
+		return nil, fmt.Errorf("unable to apply delta %v to a simulated GSet", delta)
+	}
+	added := make(map[string]*any.Any)
+	for _, a := range d.GetAdded() {
+		s.elems[anyKey(a)] = a
+		added[anyKey(a)] = a
+	}
+	return &simGSet{elems: added}, nil
+}
+
+func (s *simGSet) merge(other simState) error {
+	o, ok := other.(*simGSet)
+	if !ok {
+		return fmt.Errorf("unable to merge %T into a simulated GSet", other)
+	}
+	for k, a := range o.elems {
+		s.elems[k] = a
+	}
+	return nil
+}
+
+func (s *simGSet) diff(_ *simNode, old simState) *entity.CrdtDelta {
+	o := old.(*simGSet)
+	added := make([]*any.Any, 0)
+	for k, a := range s.elems {
+		if _, ok := o.elems[k]; !ok {
+			added = append(added, a)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Gset{Gset: &entity.GSetDelta{Added: added}}}
+}
+
+func (s *simGSet) clone() simState {
+	elems := make(map[string]*any.Any, len(s.elems))
+	for k, a := range s.elems {
+		elems[k] = a
+	}
+	return &simGSet{elems: elems}
+}
+
+func (s *simGSet) empty() simState {
+	return &simGSet{elems: make(map[string]*any.Any)}
+}
+
+// simDotted is an element of an observed-remove CRDT tagged with the dots of
+// the additions that are still observed.
+type simDotted struct {
+	value *any.Any
+	dots  map[simDot]bool
+}
+
+// simDotStore holds dotted elements together with the causal context of all
+// dots ever seen, that is, added or removed.
+type simDotStore struct {
+	elems   map[string]*simDotted
+	context map[simDot]bool
+}
+
+func newSimDotStore() simDotStore {
+	return simDotStore{
+		elems:   make(map[string]*simDotted),
+		context: make(map[simDot]bool),
+	}
+}
+
+// add adds a dotted element and returns its delta-state.
+func (s *simDotStore) add(n *simNode, a *any.Any) simDotStore {
+	dot := n.nextDot()
+	k := anyKey(a)
+	e, ok := s.elems[k]
+	if !ok {
+		e = &simDotted{value: a, dots: make(map[simDot]bool)}
+		s.elems[k] = e
+	}
+	e.dots[dot] = true
+	s.context[dot] = true
+	delta := newSimDotStore()
+	delta.elems[k] = &simDotted{value: a, dots: map[simDot]bool{dot: true}}
+	delta.context[dot] = true
+	return delta
+}
+
+// remove removes an element by its observed dots and adds them to the delta.
+func (s *simDotStore) remove(a *any.Any, delta *simDotStore) {
+	k := anyKey(a)
+	e, ok := s.elems[k]
+	if !ok {
+		return
+	}
+	for dot := range e.dots {
+		delta.context[dot] = true
+	}
+	delete(s.elems, k)
+}
+
+func (s *simDotStore) clear(delta *simDotStore) {
+	for _, e := range s.elems {
+		for dot := range e.dots {
+			delta.context[dot] = true
+		}
+	}
+	s.elems = make(map[string]*simDotted)
+}
+
+// join merges another dot store into this one. An element survives with the
+// dots both stores know about and with the dots the other store has not seen.
+func (s *simDotStore) join(o *simDotStore) {
+	keys := make(map[string]*any.Any)
+	for k, e := range s.elems {
+		keys[k] = e.value
+	}
+	for k, e := range o.elems {
+		keys[k] = e.value
+	}
+	for k, value := range keys {
+		dots := make(map[simDot]bool)
+		if e, ok := s.elems[k]; ok {
+			for dot := range e.dots {
+				if !o.context[dot] || o.elems[k] != nil && o.elems[k].dots[dot] {
+					dots[dot] = true
+				}
+			}
+		}
+		if e, ok := o.elems[k]; ok {
+			for dot := range e.dots {
+				if !s.context[dot] || s.elems[k] != nil && s.elems[k].dots[dot] {
+					dots[dot] = true
+				}
+			}
+		}
+		if len(dots) == 0 {
+			delete(s.elems, k)
+			continue
+		}
+		s.elems[k] = &simDotted{value: value, dots: dots}
+	}
+	for dot := range o.context {
+		s.context[dot] = true
+	}
+}
+
+func (s *simDotStore) clone() simDotStore {
+	c := newSimDotStore()
+	for k, e := range s.elems {
+		dots := make(map[simDot]bool, len(e.dots))
+		for dot := range e.dots {
+			dots[dot] = true
+		}
+		c.elems[k] = &simDotted{value: e.value, dots: dots}
+	}
+	for dot := range s.context {
+		c.context[dot] = true
+	}
+	return c
+}
+
+// changes returns the elements removed and added compared to an old store.
+func (s *simDotStore) changes(old *simDotStore) (removed, added []*any.Any) {
+	removed = make([]*any.Any, 0)
+	added = make([]*any.Any, 0)
+	for k, e := range old.elems {
+		if _, ok := s.elems[k]; !ok {
+			removed = append(removed, e.value)
+		}
+	}
+	for k, e := range s.elems {
+		if _, ok := old.elems[k]; !ok {
+			added = append(added, e.value)
+		}
+	}
+	return
+}
+
+type simORSet struct {
+	store simDotStore
+}
+
+func newSimORSet() *simORSet {
+	return &simORSet{store: newSimDotStore()}
+}
+
+func (s *simORSet) local(n *simNode, delta *entity.CrdtDelta) (simState, error) {
+	d := delta.GetOrset()
+	if d == nil {
+		return nil, fmt.Errorf("unable to apply delta %v to a simulated ORSet", delta)
+	}
+	out := newSimDotStore()
+	if d.GetCleared() {
+		s.store.clear(&out)
+	}
+	for _, r := range d.GetRemoved() {
+		s.store.remove(r, &out)
+	}
+	for _, a := range d.GetAdded() {
+		added := s.store.add(n, a)
+		out.join(&added)
+	}
+	return &simORSet{store: out}, nil
+}
+
+func (s *simORSet) merge(other simState) error {
+	o, ok := other.(*simORSet)
+	if !ok {
+		return fmt.Errorf("unable to merge %T into a simulated ORSet", other)
+	}
+	s.store.join(&o.store)
+	return nil
+}
+
+func (s *simORSet) diff(_ *simNode, old simState) *entity.CrdtDelta {
+	removed, added := s.store.changes(&old.(*simORSet).store)
+	if len(removed) == 0 && len(added) == 0 {
+		return nil
+	}
+	return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Orset{Orset: &entity.ORSetDelta{Removed: removed, Added: added}}}
+}
+
+func (s *simORSet) clone() simState {
+	return &simORSet{store: s.store.clone()}
+}
+
+func (s *simORSet) empty() simState {
+	return newSimORSet()
+}
+
+type simLWWRegister struct {
+	value     *any.Any
+	timestamp int64
+	node      int
+	set       bool
+}
+
+func (r *simLWWRegister) local(n *simNode, delta *entity.CrdtDelta) (simState, error) {
+	d := delta.GetLwwregister()
+	if d == nil {
+		return nil, fmt.Errorf("unable to apply delta %v to a simulated LWWRegister", delta)
+	}
+	var timestamp int64
+	switch d.GetClock() {
+	case entity.CrdtClock_DEFAULT:
+		timestamp = n.now()
+	case entity.CrdtClock_REVERSE:
+		timestamp = -n.now()
+	case entity.CrdtClock_CUSTOM:
+		timestamp = d.GetCustomClockValue()
+	case entity.CrdtClock_CUSTOM_AUTO_INCREMENT:
+		timestamp = d.GetCustomClockValue()
+		if r.set && r.timestamp >= timestamp {
+			timestamp = r.timestamp + 1
+		}
+	}
+	r.value, r.timestamp, r.node, r.set = d.GetValue(), timestamp, n.id, true
+	return r.clone(), nil
+}
+
+func (r *simLWWRegister) merge(other simState) error {
+	o, ok := other.(*simLWWRegister)
+	if !ok {
+		return fmt.Errorf("unable to merge %T into a simulated LWWRegister", other)
+	}
+	if !o.set {
+		return nil
+	}
+	if !r.set || o.timestamp > r.timestamp || o.timestamp == r.timestamp && o.node < r.node {
+		r.value, r.timestamp, r.node, r.set = o.value, o.timestamp, o.node, true
+	}
+	return nil
+}
+
+func (r *simLWWRegister) diff(_ *simNode, old simState) *entity.CrdtDelta {
+	o := old.(*simLWWRegister)
+	if r.set == o.set && proto.Equal(r.value, o.value) {
+		return nil
+	}
+	return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Lwwregister{Lwwregister: &entity.LWWRegisterDelta{Value: r.value}}}
+}
+
+func (r *simLWWRegister) clone() simState {
+	c := *r
+	return &c
+}
+
+func (r *simLWWRegister) empty() simState {
+	return &simLWWRegister{}
+}
+
+// simBallot is a vote of a node, versioned by the node itself.
+type simBallot struct {
+	vote    bool
+	version uint64
+}
+
+type simVote struct {
+	votes map[int]simBallot
+}
+
+func (v *simVote) local(n *simNode, delta *entity.CrdtDelta) (simState, error) {
+	d := delta.GetVote()
+	if d == nil {
+		return nil, fmt.Errorf("unable to apply delta %v to a simulated Vote", delta)
+	}
+	b := simBallot{vote: d.GetSelfVote(), version: v.votes[n.id].version + 1}
+	v.votes[n.id] = b
+	return &simVote{votes: map[int]simBallot{n.id: b}}, nil
+}
+
+func (v *simVote) merge(other simState) error {
+	o, ok := other.(*simVote)
+	if !ok {
+		return fmt.Errorf("unable to merge %T into a simulated Vote", other)
+	}
+	for node, b := range o.votes {
+		if b.version > v.votes[node].version {
+			v.votes[node] = b
+		}
+	}
+	return nil
+}
+
+func (v *simVote) votesFor() (n int32) {
+	for _, b := range v.votes {
+		if b.vote {
+			n++
+		}
+	}
+	return
+}
+
+func (v *simVote) diff(n *simNode, old simState) *entity.CrdtDelta {
+	return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Vote{Vote: &entity.VoteDelta{
+		SelfVote:    v.votes[n.id].vote,
+		VotesFor:    v.votesFor(),
+		TotalVoters: int32(n.replicas),
+	}}}
+}
+
+func (v *simVote) clone() simState {
+	votes := make(map[int]simBallot, len(v.votes))
+	for node, b := range v.votes {
+		votes[node] = b
+	}
+	return &simVote{votes: votes}
+}
+
+func (v *simVote) empty() simState {
+	return &simVote{votes: make(map[int]simBallot)}
+}
+
+// simORMap keeps its keys in a dot store like an ORSet does. Values are merged
+// independently of their keys, so a value of a key removed and added again is
+// merged with its previous value, just like the proxy does.
+type simORMap struct {
+	keys   simDotStore
+	values map[string]simState
+}
+
+func newSimORMap() *simORMap {
+	return &simORMap{keys: newSimDotStore(), values: make(map[string]simState)}
+}
+
+func (m *simORMap) local(n *simNode, delta *entity.CrdtDelta) (simState, error) {
+	d := delta.GetOrmap()
+	if d == nil {
+		return nil, fmt.Errorf("unable to apply delta %v to a simulated ORMap", delta)
+	}
+	out := newSimORMap()
+	if d.GetCleared() {
+		m.keys.clear(&out.keys)
+	}
+	for _, r := range d.GetRemoved() {
+		m.keys.remove(r, &out.keys)
+	}
+	for _, a := range d.GetAdded() {
+		added := m.keys.add(n, a.GetKey())
+		out.keys.join(&added)
+		if err := m.localValue(n, a.GetKey(), a.GetDelta(), out); err != nil {
+			return nil, err
+		}
+	}
+	for _, u := range d.GetUpdated() {
+		if err := m.localValue(n, u.GetKey(), u.GetDelta(), out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (m *simORMap) localValue(n *simNode, key *any.Any, delta *entity.CrdtDelta, out *simORMap) error {
+	k := anyKey(key)
+	value, ok := m.values[k]
+	if !ok {
+		var err error
+		if value, err = newSimStateForDelta(delta); err != nil {
+			return err
+		}
+		m.values[k] = value
+	}
+	valueDelta, err := value.local(n, delta)
+	if err != nil {
+		return err
+	}
+	out.values[k] = valueDelta
+	return nil
+}
+
+func (m *simORMap) merge(other simState) error {
+	o, ok := other.(*simORMap)
+	if !ok {
+		return fmt.Errorf("unable to merge %T into a simulated ORMap", other)
+	}
+	m.keys.join(&o.keys)
+	for k, v := range o.values {
+		value, ok := m.values[k]
+		if !ok {
+			m.values[k] = v.clone()
+			continue
+		}
+		if err := value.merge(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *simORMap) diff(n *simNode, old simState) *entity.CrdtDelta {
+	o := old.(*simORMap)
+	removed, _ := m.keys.changes(&o.keys)
+	added := make([]*entity.ORMapEntryDelta, 0)
+	updated := make([]*entity.ORMapEntryDelta, 0)
+	for k, e := range m.keys.elems {
+		value := m.values[k]
+		if _, ok := o.keys.elems[k]; !ok {
+			delta := value.diff(n, value.empty())
+			if delta == nil {
+				delta = simZeroDelta(value)
+			}
+			added = append(added, &entity.ORMapEntryDelta{Key: e.value, Delta: delta})
+			continue
+		}
+		oldValue, ok := o.values[k]
+		if !ok {
+			oldValue = value.empty()
+		}
+		if delta := value.diff(n, oldValue); delta != nil {
+			updated = append(updated, &entity.ORMapEntryDelta{Key: e.value, Delta: delta})
+		}
+	}
+	if len(removed) == 0 && len(added) == 0 && len(updated) == 0 {
+		return nil
+	}
+	return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Ormap{Ormap: &entity.ORMapDelta{
+		Removed: removed,
+		Added:   added,
+		Updated: updated,
+	}}}
+}
+
+func (m *simORMap) clone() simState {
+	values := make(map[string]simState, len(m.values))
+	for k, v := range m.values {
+		values[k] = v.clone()
+	}
+	return &simORMap{keys: m.keys.clone(), values: values}
+}
+
+func (m *simORMap) empty() simState {
+	return newSimORMap()
+}
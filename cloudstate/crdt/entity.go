@@ -18,6 +18,7 @@ package crdt
 import (
 	"time"
 
+	"github.com/cloudstateio/go-support/cloudstate/entity"
 	"github.com/cloudstateio/go-support/cloudstate/protocol"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
@@ -32,6 +33,27 @@ type Entity struct {
 	// EntityFunc creates a new entity.
 	EntityFunc          func(id EntityID) EntityHandler
 	PassivationStrategy protocol.EntityPassivationStrategy
+	// WriteConsistency is the default write consistency used for state
+	// actions of commands handled by this entity.
+	WriteConsistency entity.CrdtWriteConsistency
+	// CommandWriteConsistency overrides WriteConsistency by command name.
+	CommandWriteConsistency map[string]entity.CrdtWriteConsistency
+	// KeyCanonicalizer is set for the ORSet, GSet and ORMap instances of
+	// the entities state.
+	KeyCanonicalizer KeyCanonicalizer
+	// Limits limit the growth of each CRDT of the entity.
+	Limits Limits
+	// LimitUsageFunc, if set, is called with the limit usage of the entity
+	// after each command.
+	LimitUsageFunc LimitUsageFunc
+	// ClockSource is used by LWWRegister and LWWMap instances of the entity
+	// without a clock source of their own.
+	ClockSource ClockSource
+	// Interceptors intercept the commands of the entity.
+	Interceptors []protocol.Interceptor
+	// Timeout bounds the time a command is handled if positive. A command
+	// handler overrunning it fails the command.
+	Timeout time.Duration
 }
 
 type Option func(s *Entity)
@@ -54,6 +76,81 @@ func WithPassivationStrategyTimeout(duration time.Duration) Option {
 	}
 }
 
+// WithWriteConsistency sets the default write consistency for the entity.
+func WithWriteConsistency(wc entity.CrdtWriteConsistency) Option {
+	return func(e *Entity) {
+		e.WriteConsistency = wc
+	}
+}
+
+// WithCommandWriteConsistency sets the write consistency for the named
+// command, overriding the entity default.
+func WithCommandWriteConsistency(name string, wc entity.CrdtWriteConsistency) Option {
+	return func(e *Entity) {
+		if e.CommandWriteConsistency == nil {
+			e.CommandWriteConsistency = make(map[string]entity.CrdtWriteConsistency)
+		}
+		e.CommandWriteConsistency[name] = wc
+	}
+}
+
+// WithKeyCanonicalizer sets the key canonicalizer for the entities ORSet,
+// GSet and ORMap instances.
+func WithKeyCanonicalizer(c KeyCanonicalizer) Option {
+	return func(e *Entity) {
+		e.KeyCanonicalizer = c
+	}
+}
+
+// WithLimits sets the limits for each CRDT of the entity. Commands that
+// would exceed a limit fail with a client error and their changes are not
+// replicated. The rejected change itself is returned by the Try variants of
+// the CRDTs setters, like GSet.TryAdd.
+func WithLimits(l Limits) Option {
+	return func(e *Entity) {
+		e.Limits = l
+	}
+}
+
+// WithLimitUsage sets a function to be called with the limit usage of the
+// entity after each command.
+func WithLimitUsage(f LimitUsageFunc) Option {
+	return func(e *Entity) {
+		e.LimitUsageFunc = f
+	}
+}
+
+// WithClockSource sets the clock source for the LWWRegister and LWWMap
+// instances of the entity.
+func WithClockSource(source ClockSource) Option {
+	return func(e *Entity) {
+		e.ClockSource = source
+	}
+}
+
+// WithInterceptors adds interceptors for the commands of the entity.
+func WithInterceptors(interceptors ...protocol.Interceptor) Option {
+	return func(e *Entity) {
+		e.Interceptors = append(e.Interceptors, interceptors...)
+	}
+}
+
+// WithTimeout bounds the time a command of the entity is handled. A
+// grpc-timeout or deadline metadata entry of a command may shorten it.
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Entity) {
+		e.Timeout = timeout
+	}
+}
+
+// writeConsistencyFor returns the write consistency configured for a command.
+func (e *Entity) writeConsistencyFor(name string) entity.CrdtWriteConsistency {
+	if wc, ok := e.CommandWriteConsistency[name]; ok {
+		return wc
+	}
+	return e.WriteConsistency
+}
+
 // EntityHandler has to be implemented by any type that wants to get
 // registered as a crdt.Entity
 // tag::entity-handler[]
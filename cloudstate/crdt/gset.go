@@ -19,6 +19,7 @@ import (
 	"fmt"
 
 	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
 )
 
@@ -28,6 +29,9 @@ import (
 type GSet struct {
 	value map[uint64]*any.Any
 	added map[uint64]*any.Any
+	// size is the encoded size of the values.
+	size  int
+	guard *sizeGuard
 	*anyHasher
 }
 
@@ -46,12 +50,22 @@ func (s GSet) Size() int {
 }
 
 func (s *GSet) Add(a *any.Any) {
+	_ = s.TryAdd(a)
+}
+
+// TryAdd adds a and returns a LimitError if a has been rejected by the
+// limits of the entity.
+func (s *GSet) TryAdd(a *any.Any) error {
 	h := s.hashAny(a)
 	if _, exists := s.value[h]; exists {
-		return
+		return nil
 	}
-	s.value[h] = a
+	if err := s.guard.check(len(s.value)+1, func() int { return s.size + proto.Size(a) }); err != nil {
+		return err
+	}
+	s.put(h, a)
 	s.added[h] = a
+	return nil
 }
 
 func (s GSet) HasDelta() bool {
@@ -98,7 +112,16 @@ func (s *GSet) applyDelta(delta *entity.CrdtDelta) error {
 		return fmt.Errorf("unable to apply state %+v to GSet", delta)
 	}
 	for _, v := range d.GetAdded() {
-		s.value[s.hashAny(v)] = v
+		s.put(s.hashAny(v), v)
 	}
 	return nil
 }
+
+// put sets a value and keeps the size of the values.
+func (s *GSet) put(h uint64, a *any.Any) {
+	if old, ok := s.value[h]; ok {
+		s.size -= proto.Size(old)
+	}
+	s.value[h] = a
+	s.size += proto.Size(a)
+}
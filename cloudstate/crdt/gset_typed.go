@@ -0,0 +1,71 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+)
+
+// TypedGSet is a view on a GSet with elements of type T. Elements are
+// encoded by a codec and the underlying GSet produces the deltas.
+type TypedGSet[T interface{}] struct {
+	set   *GSet
+	codec encoding.Codec[T]
+}
+
+// GSetOf returns a TypedGSet for the given GSet.
+func GSetOf[T interface{}](s *GSet, codec encoding.Codec[T]) *TypedGSet[T] {
+	return &TypedGSet[T]{set: s, codec: codec}
+}
+
+// GSet returns the underlying GSet.
+func (s *TypedGSet[T]) GSet() *GSet {
+	return s.set
+}
+
+func (s *TypedGSet[T]) Size() int {
+	return s.set.Size()
+}
+
+func (s *TypedGSet[T]) Add(v T) error {
+	a, err := s.codec.Encode(v)
+	if err != nil {
+		return err
+	}
+	return s.set.TryAdd(a)
+}
+
+func (s *TypedGSet[T]) Contains(v T) (bool, error) {
+	a, err := s.codec.Encode(v)
+	if err != nil {
+		return false, err
+	}
+	_, ok := s.set.value[s.set.hashAny(a)]
+	return ok, nil
+}
+
+// Value returns the decoded elements of the set.
+func (s *TypedGSet[T]) Value() ([]T, error) {
+	val := make([]T, 0, len(s.set.value))
+	for _, a := range s.set.value {
+		v, err := s.codec.Decode(a)
+		if err != nil {
+			return nil, err
+		}
+		val = append(val, v)
+	}
+	return val, nil
+}
@@ -0,0 +1,53 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+)
+
+func TestTypedGSet(t *testing.T) {
+	s := GSetOf(NewGSet(), encoding.Int64Codec)
+	for _, v := range []int64{1, 2, 2} {
+		if err := s.Add(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Size() != 2 {
+		t.Fatalf("s.Size(): %v; want: %v", s.Size(), 2)
+	}
+	if has, _ := s.Contains(2); !has {
+		t.Fatal("set should contain 2")
+	}
+	if alen := len(encDecDelta(s.GSet().Delta()).GetGset().GetAdded()); alen != 2 {
+		t.Fatalf("len(GetAdded()): %v; want: %v", alen, 2)
+	}
+	value, err := s.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(value) != 2 || value[0]+value[1] != 3 {
+		t.Fatalf("s.Value(): %v; want: %v", value, []int64{1, 2})
+	}
+	s.GSet().setSizeGuard(&sizeGuard{limits: Limits{MaxElements: 2}})
+	var limitErr *LimitError
+	if err := s.Add(3); !errors.As(err, &limitErr) {
+		t.Fatalf("s.Add(3): %v; want: a limit error", err)
+	}
+}
@@ -17,15 +17,33 @@ package crdt
 
 import (
 	"hash/maphash"
+	"log"
 
 	"github.com/golang/protobuf/ptypes/any"
 )
 
 type anyHasher struct {
-	h maphash.Hash
+	h            maphash.Hash
+	canonicalize KeyCanonicalizer
 }
 
+// hashAny returns the hash of the canonical form of a. Only the hash is
+// canonical, keys are kept as received so that they are sent back to the
+// proxy in the same encoding. A key the canonicalizer fails for is hashed
+// as it is.
 func (h *anyHasher) hashAny(a *any.Any) uint64 {
+	if h.canonicalize != nil {
+		c, err := h.canonicalize(a)
+		if err != nil {
+			log.Printf("unable to canonicalize key: %v, it is used as it is: %v", a, err)
+		} else if c != nil {
+			a = c
+		}
+	}
+	return h.hash(a)
+}
+
+func (h *anyHasher) hash(a *any.Any) uint64 {
 	h.h.Reset()
 	_, _ = h.h.WriteString(a.GetTypeUrl()) // does never err
 	_, _ = h.h.Write(a.GetValue())         // does never err
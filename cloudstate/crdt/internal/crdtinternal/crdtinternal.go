@@ -0,0 +1,29 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crdtinternal gives packages of the crdt tree access to unexported
+// parts of the crdt package without them becoming part of its API.
+package crdtinternal
+
+import (
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+)
+
+var (
+	// ApplyDelta applies a delta received from the proxy to a CRDT.
+	ApplyDelta func(c interface{}, delta *entity.CrdtDelta) error
+	// ResetDelta clears the delta of a CRDT as if it was sent.
+	ResetDelta func(c interface{})
+)
@@ -0,0 +1,417 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// crdtJSON is the canonical JSON representation of a CRDT.
+type crdtJSON struct {
+	Type             string          `json:"type"`
+	Value            json.RawMessage `json:"value,omitempty"`
+	Elements         []*anyJSON      `json:"elements,omitempty"`
+	Entries          []*ormapJSON    `json:"entries,omitempty"`
+	Clock            string          `json:"clock,omitempty"`
+	CustomClockValue int64           `json:"customClockValue,omitempty"`
+	SelfVote         bool            `json:"selfVote,omitempty"`
+	VotesFor         uint32          `json:"votesFor,omitempty"`
+	Voters           uint32          `json:"voters,omitempty"`
+}
+
+type ormapJSON struct {
+	Key   *anyJSON  `json:"key"`
+	Value *crdtJSON `json:"value"`
+}
+
+// anyJSON represents an any.Any with its value decoded where possible.
+// Primitives, Cloudstate JSON and registered protobuf messages are decoded,
+// anything else is kept as bytes.
+type anyJSON struct {
+	Type  string          `json:"@type"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Bytes []byte          `json:"bytes,omitempty"`
+}
+
+const (
+	flagJSONType        = "flag"
+	gcounterJSONType    = "gcounter"
+	pncounterJSONType   = "pncounter"
+	gsetJSONType        = "gset"
+	orsetJSONType       = "orset"
+	lwwregisterJSONType = "lwwregister"
+	ormapJSONType       = "ormap"
+	voteJSONType        = "vote"
+)
+
+// MarshalJSON returns the canonical JSON representation of a CRDT. Elements
+// of sets and entries of maps are sorted, so that equal CRDTs have equal
+// representations.
+func MarshalJSON(c CRDT) ([]byte, error) {
+	j, err := toJSON(c)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON returns a delta that initializes a CRDT to the state
+// represented by data as returned by MarshalJSON. The delta can be used
+// as the initial delta of a CrdtInit message.
+func UnmarshalJSON(data []byte) (*entity.CrdtDelta, error) {
+	var j crdtJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	return j.delta()
+}
+
+// NewFromJSON returns a CRDT with the state represented by data.
+func NewFromJSON(data []byte) (CRDT, error) {
+	delta, err := UnmarshalJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newFor(delta)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.applyDelta(delta); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func toJSON(c CRDT) (*crdtJSON, error) {
+	switch t := c.(type) {
+	case *Flag:
+		return valueJSON(flagJSONType, t.Value())
+	case *GCounter:
+		return valueJSON(gcounterJSONType, t.Value())
+	case *PNCounter:
+		return valueJSON(pncounterJSONType, t.Value())
+	case *GSet:
+		elements, err := anysToJSON(t.Value())
+		if err != nil {
+			return nil, err
+		}
+		return &crdtJSON{Type: gsetJSONType, Elements: elements}, nil
+	case *ORSet:
+		elements, err := anysToJSON(t.Value())
+		if err != nil {
+			return nil, err
+		}
+		return &crdtJSON{Type: orsetJSONType, Elements: elements}, nil
+	case *LWWRegister:
+		j := &crdtJSON{
+			Type:             lwwregisterJSONType,
+			Clock:            t.clock.toCrdtClock().String(),
+			CustomClockValue: t.customClockValue,
+		}
+		if t.Value() != nil {
+			value, err := anyToJSON(t.Value())
+			if err != nil {
+				return nil, err
+			}
+			if j.Value, err = json.Marshal(value); err != nil {
+				return nil, err
+			}
+		}
+		return j, nil
+	case *Vote:
+		return &crdtJSON{
+			Type:     voteJSONType,
+			SelfVote: t.SelfVote(),
+			VotesFor: t.VotesFor(),
+			Voters:   t.Voters(),
+		}, nil
+	case *LWWMap:
+		return toJSON(t.m)
+	case *MVRegister:
+		return toJSON(t.set)
+	case *ORMap:
+		entries := make([]*ormapJSON, 0, t.Size())
+		for _, e := range t.Entries() {
+			key, err := anyToJSON(e.Key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := toJSON(e.Value)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, &ormapJSON{Key: key, Value: value})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Key.less(entries[j].Key)
+		})
+		return &crdtJSON{Type: ormapJSONType, Entries: entries}, nil
+	default:
+		return nil, fmt.Errorf("no JSON representation for CRDT type: %T", c)
+	}
+}
+
+func valueJSON(typ string, value interface{}) (*crdtJSON, error) {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return &crdtJSON{Type: typ, Value: v}, nil
+}
+
+func (j *crdtJSON) delta() (*entity.CrdtDelta, error) {
+	switch j.Type {
+	case flagJSONType:
+		var v bool
+		if err := j.unmarshalValue(&v); err != nil {
+			return nil, err
+		}
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Flag{Flag: &entity.FlagDelta{Value: v}}}, nil
+	case gcounterJSONType:
+		var v uint64
+		if err := j.unmarshalValue(&v); err != nil {
+			return nil, err
+		}
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Gcounter{Gcounter: &entity.GCounterDelta{Increment: v}}}, nil
+	case pncounterJSONType:
+		var v int64
+		if err := j.unmarshalValue(&v); err != nil {
+			return nil, err
+		}
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Pncounter{Pncounter: &entity.PNCounterDelta{Change: v}}}, nil
+	case gsetJSONType:
+		added, err := anysFromJSON(j.Elements)
+		if err != nil {
+			return nil, err
+		}
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Gset{Gset: &entity.GSetDelta{Added: added}}}, nil
+	case orsetJSONType:
+		added, err := anysFromJSON(j.Elements)
+		if err != nil {
+			return nil, err
+		}
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Orset{Orset: &entity.ORSetDelta{Added: added}}}, nil
+	case lwwregisterJSONType:
+		d := &entity.LWWRegisterDelta{CustomClockValue: j.CustomClockValue}
+		if j.Clock != "" {
+			clock, ok := entity.CrdtClock_value[j.Clock]
+			if !ok {
+				return nil, fmt.Errorf("unknown clock: %q", j.Clock)
+			}
+			d.Clock = entity.CrdtClock(clock)
+		}
+		if len(j.Value) > 0 {
+			var value anyJSON
+			if err := json.Unmarshal(j.Value, &value); err != nil {
+				return nil, err
+			}
+			var err error
+			if d.Value, err = value.toAny(); err != nil {
+				return nil, err
+			}
+		}
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Lwwregister{Lwwregister: d}}, nil
+	case voteJSONType:
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Vote{Vote: &entity.VoteDelta{
+			SelfVote:    j.SelfVote,
+			VotesFor:    int32(j.VotesFor),
+			TotalVoters: int32(j.Voters),
+		}}}, nil
+	case ormapJSONType:
+		added := make([]*entity.ORMapEntryDelta, 0, len(j.Entries))
+		for _, e := range j.Entries {
+			if e.Key == nil || e.Value == nil {
+				return nil, fmt.Errorf("ORMap entry without a key or value: %+v", e)
+			}
+			key, err := e.Key.toAny()
+			if err != nil {
+				return nil, err
+			}
+			delta, err := e.Value.delta()
+			if err != nil {
+				return nil, err
+			}
+			added = append(added, &entity.ORMapEntryDelta{Key: key, Delta: delta})
+		}
+		return &entity.CrdtDelta{Delta: &entity.CrdtDelta_Ormap{Ormap: &entity.ORMapDelta{Added: added}}}, nil
+	default:
+		return nil, fmt.Errorf("unknown CRDT type: %q", j.Type)
+	}
+}
+
+func (j *crdtJSON) unmarshalValue(v interface{}) error {
+	if len(j.Value) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(j.Value, v); err != nil {
+		return fmt.Errorf("invalid value for CRDT type: %q: %w", j.Type, err)
+	}
+	return nil
+}
+
+func anysToJSON(values []*any.Any) ([]*anyJSON, error) {
+	elements := make([]*anyJSON, len(values))
+	for i, v := range values {
+		var err error
+		if elements[i], err = anyToJSON(v); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(elements, func(i, j int) bool {
+		return elements[i].less(elements[j])
+	})
+	return elements, nil
+}
+
+func anysFromJSON(elements []*anyJSON) ([]*any.Any, error) {
+	values := make([]*any.Any, len(elements))
+	for i, e := range elements {
+		var err error
+		if values[i], err = e.toAny(); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func anyToJSON(a *any.Any) (*anyJSON, error) {
+	typeURL := a.GetTypeUrl()
+	switch {
+	case strings.HasPrefix(typeURL, encoding.PrimitiveTypeURLPrefix+"/"):
+		v, err := encoding.UnmarshalPrimitive(a)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			value, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			return &anyJSON{Type: typeURL, Value: value}, nil
+		}
+	case strings.HasPrefix(typeURL, encoding.JSONTypeURLPrefix+"/"):
+		var value json.RawMessage
+		if err := encoding.UnmarshalJSON(a, &value); err == nil {
+			return &anyJSON{Type: typeURL, Value: value}, nil
+		}
+	default:
+		if mt, err := protoregistry.GlobalTypes.FindMessageByURL(typeURL); err == nil {
+			m := mt.New().Interface()
+			if err := proto.Unmarshal(a.GetValue(), m); err == nil {
+				value, err := protojson.Marshal(m)
+				if err != nil {
+					return nil, err
+				}
+				// protojson does not produce a stable output, compacting it does.
+				var buf bytes.Buffer
+				if err := json.Compact(&buf, value); err != nil {
+					return nil, err
+				}
+				return &anyJSON{Type: typeURL, Value: buf.Bytes()}, nil
+			}
+		}
+	}
+	return &anyJSON{Type: typeURL, Bytes: a.GetValue()}, nil
+}
+
+func (j *anyJSON) toAny() (*any.Any, error) {
+	if j.Value == nil {
+		return &any.Any{TypeUrl: j.Type, Value: j.Bytes}, nil
+	}
+	switch {
+	case strings.HasPrefix(j.Type, encoding.PrimitiveTypeURLPrefix+"/"):
+		var v interface{}
+		switch j.Type {
+		case encoding.PrimitiveTypeURLPrefixInt32:
+			v = new(int32)
+		case encoding.PrimitiveTypeURLPrefixInt64:
+			v = new(int64)
+		case encoding.PrimitiveTypeURLPrefixString:
+			v = new(string)
+		case encoding.PrimitiveTypeURLPrefixFloat:
+			v = new(float32)
+		case encoding.PrimitiveTypeURLPrefixDouble:
+			v = new(float64)
+		case encoding.PrimitiveTypeURLPrefixBool:
+			v = new(bool)
+		case encoding.PrimitiveTypeURLPrefixBytes:
+			v = new([]byte)
+		default:
+			return nil, fmt.Errorf("unknown primitive type: %q", j.Type)
+		}
+		if err := json.Unmarshal(j.Value, v); err != nil {
+			return nil, err
+		}
+		switch p := v.(type) {
+		case *int32:
+			return encoding.MarshalPrimitive(*p)
+		case *int64:
+			return encoding.MarshalPrimitive(*p)
+		case *string:
+			return encoding.MarshalPrimitive(*p)
+		case *float32:
+			return encoding.MarshalPrimitive(*p)
+		case *float64:
+			return encoding.MarshalPrimitive(*p)
+		case *bool:
+			return encoding.MarshalPrimitive(*p)
+		default:
+			return encoding.MarshalPrimitive(*v.(*[]byte))
+		}
+	case strings.HasPrefix(j.Type, encoding.JSONTypeURLPrefix+"/"):
+		a, err := encoding.MarshalJSON(j.Value)
+		if err != nil {
+			return nil, err
+		}
+		a.TypeUrl = j.Type
+		return a, nil
+	default:
+		mt, err := protoregistry.GlobalTypes.FindMessageByURL(j.Type)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode a value of type: %q: %w", j.Type, err)
+		}
+		m := mt.New().Interface()
+		if err := protojson.Unmarshal(j.Value, m); err != nil {
+			return nil, err
+		}
+		value, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		return &any.Any{TypeUrl: j.Type, Value: value}, nil
+	}
+}
+
+func (j *anyJSON) less(o *anyJSON) bool {
+	if j.Type != o.Type {
+		return j.Type < o.Type
+	}
+	if c := bytes.Compare(j.Value, o.Value); c != 0 {
+		return c < 0
+	}
+	return bytes.Compare(j.Bytes, o.Bytes) < 0
+}
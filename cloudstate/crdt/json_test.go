@@ -0,0 +1,171 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	t.Run("counters and flags marshal their value", func(t *testing.T) {
+		c := NewPNCounter()
+		c.Increment(7)
+		c.Decrement(2)
+		got, err := MarshalJSON(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"type":"pncounter","value":5}`; string(got) != want {
+			t.Fatalf("MarshalJSON(): %s; want: %s", got, want)
+		}
+	})
+
+	t.Run("set elements are decoded and sorted", func(t *testing.T) {
+		s := NewORSet()
+		s.Add(encoding.String("b"))
+		s.Add(encoding.String("a"))
+		s.Add(encoding.Int64(3))
+		got, err := MarshalJSON(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"type":"orset","elements":[` +
+			`{"@type":"p.cloudstate.io/int64","value":3},` +
+			`{"@type":"p.cloudstate.io/string","value":"a"},` +
+			`{"@type":"p.cloudstate.io/string","value":"b"}]}`
+		if string(got) != want {
+			t.Fatalf("MarshalJSON(): %s; want: %s", got, want)
+		}
+	})
+
+	t.Run("proto messages are decoded", func(t *testing.T) {
+		a, err := encoding.MarshalAny(&wrappers.StringValue{Value: "v"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := MarshalJSON(NewLWWRegister(a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"type":"lwwregister","value":{"@type":"type.googleapis.com/google.protobuf.StringValue","value":"v"},"clock":"DEFAULT"}`
+		if string(got) != want {
+			t.Fatalf("MarshalJSON(): %s; want: %s", got, want)
+		}
+	})
+
+	t.Run("unknown types are kept as bytes", func(t *testing.T) {
+		s := NewGSet()
+		s.Add(&any.Any{TypeUrl: "example.com/unknown", Value: []byte{1, 2}})
+		got, err := MarshalJSON(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := `{"type":"gset","elements":[{"@type":"example.com/unknown","bytes":"AQI="}]}`; string(got) != want {
+			t.Fatalf("MarshalJSON(): %s; want: %s", got, want)
+		}
+	})
+}
+
+type jsonOwner struct {
+	Name string `json:"name"`
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	newTree := func() *ORMap {
+		m := NewORMap()
+		users := NewORSet()
+		users.Add(encoding.String("alice"))
+		users.Add(encoding.String("bob"))
+		m.Set(encoding.String("users"), users)
+		owner, err := encoding.JSON(jsonOwner{Name: "alice"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Set(encoding.String("owner"), NewLWWRegister(owner))
+		v := NewVote()
+		v.Vote(true)
+		m.Set(encoding.Int32(1), v)
+		m.Set(encoding.String("enabled"), NewFlag())
+		return m
+	}
+
+	t.Run("a round trip keeps the representation", func(t *testing.T) {
+		want, err := MarshalJSON(newTree())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := NewFromJSON(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := MarshalJSON(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Fatalf("MarshalJSON(): %s; want: %s", got, want)
+		}
+	})
+
+	t.Run("a delta loads as initial state", func(t *testing.T) {
+		data, err := MarshalJSON(newTree())
+		if err != nil {
+			t.Fatal(err)
+		}
+		delta, err := UnmarshalJSON(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &runner{context: &Context{}}
+		if err := r.handleDelta(delta); err != nil {
+			t.Fatal(err)
+		}
+		users, err := r.context.crdt.(*ORMap).ORSet(encoding.String("users"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := users.Size(); s != 2 {
+			t.Fatalf("users.Size(): %v; want: %v", s, 2)
+		}
+		owner, err := r.context.crdt.(*ORMap).LWWRegister(encoding.String("owner"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v jsonOwner
+		if err := encoding.UnmarshalJSON(owner.Value(), &v); err != nil {
+			t.Fatal(err)
+		}
+		if v.Name != "alice" {
+			t.Fatalf("owner: %v; want: %v", v.Name, "alice")
+		}
+	})
+
+	t.Run("unknown types fail", func(t *testing.T) {
+		if _, err := UnmarshalJSON([]byte(`{"type":"list"}`)); err == nil {
+			t.Fatal("UnmarshalJSON(): nil error; want: error")
+		}
+	})
+
+	t.Run("invalid values fail", func(t *testing.T) {
+		if _, err := UnmarshalJSON([]byte(`{"type":"gcounter","value":"x"}`)); err == nil {
+			t.Fatal("UnmarshalJSON(): nil error; want: error")
+		}
+	})
+}
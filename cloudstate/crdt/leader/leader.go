@@ -0,0 +1,334 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package leader implements leader election backed by CRDTs.
+//
+// Candidates campaign through a streamed command. The leader holds a lease
+// stored in an LWWRegister and has to renew it before it expires. Every
+// new leadership gets a fencing token of a GCounter and the replica that
+// acquired the lease, which downstream systems can use to reject writes of
+// a former leader. A Vote tracks the
+// nodes with campaigning candidates and a candidate is leader only while
+// its node votes.
+//
+// Concurrent acquisitions of a lease on different nodes are resolved by the
+// LWWRegister. A candidate learns about having lost a lease through the
+// change of the state, so it must not act as a leader before its status has
+// been confirmed or its lease is renewed.
+package leader
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/cloudstateio/go-support/cloudstate/crdt"
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+var (
+	candidatesKey = encoding.String("candidates")
+	leaseKey      = encoding.String("lease")
+	fencingKey    = encoding.String("fencing")
+)
+
+// Config configures an Election.
+type Config struct {
+	// Lease is the duration a lease is valid after it was acquired or
+	// renewed.
+	Lease time.Duration
+	// Replica identifies the replica of the election in fencing tokens. It
+	// has to be unique among the nodes, a random ID is used if not set.
+	Replica string
+	// Now returns the current time, time.Now if not set.
+	Now func() time.Time
+}
+
+func (c Config) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// A Token is a fencing token. The counter of a token is increased for every
+// new leadership. Leases acquired concurrently on different replicas may
+// get the same counter, their tokens still differ by the replica.
+type Token struct {
+	Counter uint64 `json:"counter"`
+	Replica string `json:"replica"`
+}
+
+// Less reports whether t was handed out before o. Tokens of concurrent
+// leaderships are ordered by their replica.
+func (t Token) Less(o Token) bool {
+	if t.Counter != o.Counter {
+		return t.Counter < o.Counter
+	}
+	return t.Replica < o.Replica
+}
+
+// A Lease is held by the leader.
+type Lease struct {
+	Leader  string    `json:"leader"`
+	Token   Token     `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// Status is the status of a candidate.
+type Status struct {
+	// Leader is true if the candidate holds the lease.
+	Leader bool
+	// Lease is the current lease, nil if there is none.
+	Lease *Lease
+}
+
+func (s Status) token() Token {
+	if s.Lease == nil {
+		return Token{}
+	}
+	return s.Lease.Token
+}
+
+// A StatusFunc returns the reply for a status.
+type StatusFunc func(s Status) (*any.Any, error)
+
+// An Election elects a leader among candidates.
+type Election struct {
+	state      *crdt.ORMap
+	candidates *crdt.Vote
+	fencing    *crdt.GCounter
+	config     Config
+	// campaigns counts the campaigns per candidate of this node.
+	campaigns map[string]int
+}
+
+// New returns an Election with its state held by an ORMap.
+func New(state *crdt.ORMap, config Config) (*Election, error) {
+	if config.Lease <= 0 {
+		return nil, errors.New("the lease has to be positive")
+	}
+	if config.Replica == "" {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		config.Replica = hex.EncodeToString(id)
+	}
+	candidates, err := state.GetOrCreateVote(candidatesKey)
+	if err != nil {
+		return nil, err
+	}
+	fencing, err := state.GetOrCreateGCounter(fencingKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Election{
+		state:      state,
+		candidates: candidates,
+		fencing:    fencing,
+		config:     config,
+		campaigns:  make(map[string]int),
+	}, nil
+}
+
+// State returns the ORMap holding the elections state.
+func (e *Election) State() *crdt.ORMap {
+	return e.state
+}
+
+// Lease returns the current lease, nil if there is none.
+func (e *Election) Lease() (*Lease, error) {
+	r, err := e.state.LWWRegister(leaseKey)
+	if err != nil || r == nil || r.Value() == nil {
+		return nil, err
+	}
+	var l Lease
+	if err := encoding.DecodeStruct(r.Value(), &l); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Status returns the status of candidate.
+func (e *Election) Status(candidate string) (Status, error) {
+	l, err := e.Lease()
+	if err != nil {
+		return Status{}, err
+	}
+	return Status{
+		Leader: l != nil && l.Leader == candidate && e.valid(l) && e.candidates.SelfVote(),
+		Lease:  l,
+	}, nil
+}
+
+// Campaign lets candidate campaign for as long as the streamed command of
+// ctx is not cancelled. The candidate acquires the lease if it is free.
+// Status returns the reply for the candidates status and is streamed on
+// every change of it, including the expiry of its lease.
+func (e *Election) Campaign(ctx *crdt.CommandContext, candidate string, status StatusFunc) (*any.Any, error) {
+	if !ctx.Streamed() {
+		return nil, errors.New("campaign has to be a streamed command")
+	}
+	e.campaigns[candidate]++
+	if len(e.campaigns) == 1 && e.campaigns[candidate] == 1 {
+		e.candidates.Vote(true)
+	}
+	s, err := e.Renew(candidate)
+	if err != nil {
+		return nil, err
+	}
+	last := s
+	var expiry *crdt.Timer
+	var notify crdt.TimerFunc
+	// watch notifies a leader about the expiry of its lease, which does not
+	// change the state.
+	watch := func(c *crdt.CommandContext, s Status) {
+		if expiry != nil {
+			expiry.Stop()
+		}
+		if s.Leader {
+			expiry = c.AfterFunc(s.Lease.Expires.Sub(e.config.now()), notify)
+		}
+	}
+	notify = func(c *crdt.CommandContext) (*any.Any, error) {
+		s, err := e.Status(candidate)
+		if err != nil {
+			return nil, err
+		}
+		watch(c, s)
+		if s.Leader == last.Leader && s.token() == last.token() {
+			return nil, nil
+		}
+		last = s
+		return status(s)
+	}
+	ctx.ChangeFunc(crdt.ChangeFunc(notify))
+	ctx.CancelFunc(func(c *crdt.CommandContext) error {
+		return e.withdraw(candidate)
+	})
+	watch(ctx, s)
+	return status(s)
+}
+
+// Renew renews the lease if candidate holds it, or acquires it if the lease
+// is free, and returns the candidates status. Only a candidate campaigning
+// on this node renews or acquires the lease.
+func (e *Election) Renew(candidate string) (Status, error) {
+	l, err := e.Lease()
+	if err != nil {
+		return Status{}, err
+	}
+	now := e.config.now()
+	switch {
+	case e.campaigns[candidate] == 0:
+		return Status{Lease: l}, nil
+	case l != nil && l.Leader == candidate && e.valid(l):
+		l.Expires = now.Add(e.config.Lease)
+	case l == nil || !e.valid(l):
+		e.fencing.Increment(1)
+		l = &Lease{
+			Leader:  candidate,
+			Token:   Token{Counter: e.fencing.Value(), Replica: e.config.Replica},
+			Expires: now.Add(e.config.Lease),
+		}
+	default:
+		return Status{Lease: l}, nil
+	}
+	if err := e.setLease(l); err != nil {
+		return Status{}, err
+	}
+	return Status{Leader: true, Lease: l}, nil
+}
+
+// Resign releases the lease if candidate holds it.
+func (e *Election) Resign(candidate string) error {
+	l, err := e.Lease()
+	if err != nil || l == nil || l.Leader != candidate || !e.valid(l) {
+		return err
+	}
+	l.Expires = e.config.now()
+	return e.setLease(l)
+}
+
+func (e *Election) withdraw(candidate string) error {
+	e.campaigns[candidate]--
+	if e.campaigns[candidate] > 0 {
+		return nil
+	}
+	delete(e.campaigns, candidate)
+	if len(e.campaigns) == 0 {
+		e.candidates.Vote(false)
+	}
+	return e.Resign(candidate)
+}
+
+func (e *Election) valid(l *Lease) bool {
+	return e.config.now().Before(l.Expires)
+}
+
+func (e *Election) setLease(l *Lease) error {
+	value, err := encoding.Struct(l)
+	if err != nil {
+		return err
+	}
+	r, err := e.state.GetOrCreateLWWRegister(leaseKey, value)
+	if err != nil {
+		return err
+	}
+	r.Set(value)
+	return nil
+}
+
+// CommandHandler handles a command of an election entity.
+type CommandHandler func(ctx *crdt.CommandContext, e *Election, name string, msg proto.Message) (*any.Any, error)
+
+// Entity returns a CRDT entity for the service that holds an Election per
+// entity, for example one per scheduled job.
+func Entity(serviceName crdt.ServiceName, config Config, handler CommandHandler) *crdt.Entity {
+	return &crdt.Entity{
+		ServiceName: serviceName,
+		EntityFunc: func(id crdt.EntityID) crdt.EntityHandler {
+			return &electionEntity{config: config, handler: handler}
+		},
+	}
+}
+
+type electionEntity struct {
+	config   Config
+	handler  CommandHandler
+	election *Election
+}
+
+func (e *electionEntity) HandleCommand(ctx *crdt.CommandContext, name string, msg proto.Message) (*any.Any, error) {
+	return e.handler(ctx, e.election, name, msg)
+}
+
+func (e *electionEntity) Default(ctx *crdt.Context) (crdt.CRDT, error) {
+	return crdt.NewORMap(), nil
+}
+
+func (e *electionEntity) Set(ctx *crdt.Context, state crdt.CRDT) error {
+	m, ok := state.(*crdt.ORMap)
+	if !ok {
+		return errors.New("unable to set state, it is not an ORMap")
+	}
+	var err error
+	e.election, err = New(m, e.config)
+	return err
+}
@@ -0,0 +1,263 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package leader
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudstateio/go-support/cloudstate/crdt"
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+)
+
+type stream struct {
+	grpc.ServerStream
+	in  []*entity.CrdtStreamIn
+	out []*entity.CrdtStreamOut
+}
+
+func (s *stream) Send(out *entity.CrdtStreamOut) error {
+	s.out = append(s.out, out)
+	return nil
+}
+
+func (s *stream) Recv() (*entity.CrdtStreamIn, error) {
+	if len(s.in) == 0 {
+		return nil, io.EOF
+	}
+	in := s.in[0]
+	s.in = s.in[1:]
+	return in, nil
+}
+
+func (s *stream) Context() context.Context {
+	return context.Background()
+}
+
+// replies returns the tokens replied to a command.
+func (s *stream) replies(id int64) []int64 {
+	tokens := make([]int64, 0)
+	for _, out := range s.out {
+		if reply := out.GetReply(); reply.GetCommandId() == id {
+			tokens = append(tokens, encoding.DecodeInt64(reply.GetClientAction().GetReply().GetPayload()))
+		}
+		if m := out.GetStreamedMessage(); m.GetCommandId() == id {
+			tokens = append(tokens, encoding.DecodeInt64(m.GetClientAction().GetReply().GetPayload()))
+		}
+	}
+	return tokens
+}
+
+func command(t *testing.T, id int64, name string, candidate string, streamed bool) *entity.CrdtStreamIn {
+	t.Helper()
+	payload, err := encoding.MarshalAny(&wrappers.StringValue{Value: candidate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &entity.CrdtStreamIn{Message: &entity.CrdtStreamIn_Command{Command: &protocol.Command{
+		EntityId: "job",
+		Id:       id,
+		Name:     name,
+		Payload:  payload,
+		Streamed: streamed,
+	}}}
+}
+
+// leaderToken replies the fencing token for a leader and zero otherwise.
+func leaderToken(s Status) (*any.Any, error) {
+	if !s.Leader {
+		return encoding.Int64(0), nil
+	}
+	return encoding.Int64(int64(s.Lease.Token.Counter)), nil
+}
+
+func equal(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestElection(t *testing.T) {
+	now := time.Unix(0, 0)
+	e := Entity("test.Election", Config{Lease: 10 * time.Second, Now: func() time.Time { return now }},
+		func(ctx *crdt.CommandContext, e *Election, name string, msg proto.Message) (*any.Any, error) {
+			candidate := msg.(*wrappers.StringValue).Value
+			switch name {
+			case "Campaign":
+				return e.Campaign(ctx, candidate, leaderToken)
+			case "Renew":
+				s, err := e.Renew(candidate)
+				if err != nil {
+					return nil, err
+				}
+				return leaderToken(s)
+			}
+			return nil, nil
+		},
+	)
+	server := crdt.NewServer()
+	if err := server.Register(e); err != nil {
+		t.Fatal(err)
+	}
+	s := &stream{in: []*entity.CrdtStreamIn{
+		{Message: &entity.CrdtStreamIn_Init{Init: &entity.CrdtInit{ServiceName: "test.Election", EntityId: "job"}}},
+		command(t, 1, "Campaign", "a", true),
+		command(t, 2, "Campaign", "b", true),
+		command(t, 3, "Renew", "b", false),
+		command(t, 4, "Renew", "c", false),
+		{Message: &entity.CrdtStreamIn_StreamCancelled{StreamCancelled: &protocol.StreamCancelled{EntityId: "job", Id: 1}}},
+		command(t, 5, "Renew", "b", false),
+	}}
+	if err := server.Handle(s); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		id   int64
+		want []int64
+	}{
+		{1, []int64{1}},
+		{2, []int64{0, 2}},
+		{3, []int64{0}},
+		{4, []int64{0}},
+		{5, []int64{2}},
+	} {
+		if got := s.replies(tc.id); !equal(got, tc.want) {
+			t.Fatalf("replies for command %v: %v; want: %v", tc.id, got, tc.want)
+		}
+	}
+}
+
+func TestRenew(t *testing.T) {
+	now := time.Unix(0, 0)
+	e, err := New(crdt.NewORMap(), Config{Lease: 10 * time.Second, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.campaigns["a"] = 1
+	e.campaigns["b"] = 1
+	e.candidates.Vote(true)
+	if s, _ := e.Renew("a"); !s.Leader || s.Lease.Token.Counter != 1 {
+		t.Fatalf("Renew(a): %+v; want: leader with token 1", s)
+	}
+	now = now.Add(9 * time.Second)
+	if s, _ := e.Renew("a"); !s.Leader || !s.Lease.Expires.Equal(now.Add(10*time.Second)) {
+		t.Fatalf("Renew(a): %+v; want: a renewed lease", s)
+	}
+	now = now.Add(11 * time.Second)
+	if s, _ := e.Status("a"); s.Leader {
+		t.Fatalf("Status(a): %+v; want: an expired lease", s)
+	}
+	if s, _ := e.Renew("b"); !s.Leader || s.Lease.Token.Counter != 2 {
+		t.Fatalf("Renew(b): %+v; want: leader with token 2", s)
+	}
+}
+
+func TestConcurrentTokens(t *testing.T) {
+	tokens := make([]Token, 0, 2)
+	for _, replica := range []string{"node-1", "node-2"} {
+		e, err := New(crdt.NewORMap(), Config{Lease: 10 * time.Second, Replica: replica})
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.campaigns["a"] = 1
+		s, err := e.Renew("a")
+		if err != nil || !s.Leader {
+			t.Fatalf("Renew(a): %+v, %v; want: leader", s, err)
+		}
+		tokens = append(tokens, s.Lease.Token)
+	}
+	if tokens[0] == tokens[1] || !tokens[0].Less(tokens[1]) {
+		t.Fatalf("tokens: %+v; want distinct and ordered tokens", tokens)
+	}
+}
+
+// expiryStream blocks receiving after its messages until a message has
+// been sent that done accepts.
+type expiryStream struct {
+	stream
+	mu      sync.Mutex
+	done    func(out *entity.CrdtStreamOut) bool
+	stopped chan struct{}
+}
+
+func (s *expiryStream) Send(out *entity.CrdtStreamOut) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.out = append(s.out, out)
+	if s.done(out) {
+		close(s.stopped)
+	}
+	return nil
+}
+
+func (s *expiryStream) Recv() (*entity.CrdtStreamIn, error) {
+	s.mu.Lock()
+	if len(s.in) > 0 {
+		in := s.in[0]
+		s.in = s.in[1:]
+		s.mu.Unlock()
+		return in, nil
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.stopped:
+	case <-time.After(5 * time.Second):
+	}
+	return nil, io.EOF
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	e := Entity("test.Election", Config{Lease: 20 * time.Millisecond},
+		func(ctx *crdt.CommandContext, e *Election, name string, msg proto.Message) (*any.Any, error) {
+			return e.Campaign(ctx, msg.(*wrappers.StringValue).Value, leaderToken)
+		},
+	)
+	server := crdt.NewServer()
+	if err := server.Register(e); err != nil {
+		t.Fatal(err)
+	}
+	s := &expiryStream{
+		stream: stream{in: []*entity.CrdtStreamIn{
+			{Message: &entity.CrdtStreamIn_Init{Init: &entity.CrdtInit{ServiceName: "test.Election", EntityId: "job"}}},
+			command(t, 1, "Campaign", "a", true),
+		}},
+		done: func(out *entity.CrdtStreamOut) bool {
+			return out.GetStreamedMessage().GetCommandId() == 1
+		},
+		stopped: make(chan struct{}),
+	}
+	if err := server.Handle(s); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.replies(1), []int64{1, 0}; !equal(got, want) {
+		t.Fatalf("replies: %v; want: %v", got, want)
+	}
+}
@@ -0,0 +1,184 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// Limits limit the growth of each CRDT of an entity. A zero value is no
+// limit.
+type Limits struct {
+	// MaxElements is the maximum number of elements of a GSet or an ORSet
+	// and the maximum number of keys of an ORMap.
+	MaxElements int
+	// MaxSize is the maximum encoded size in bytes of the elements of a
+	// GSet or an ORSet, the keys of an ORMap and the value of an
+	// LWWRegister.
+	MaxSize int
+}
+
+func (l Limits) enabled() bool {
+	return l.MaxElements > 0 || l.MaxSize > 0
+}
+
+// LimitError is the error a command fails with if it would have exceeded
+// a limit.
+type LimitError struct {
+	// Limit is either "elements" or "size".
+	Limit  string
+	Max    int
+	Actual int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("CRDT %s limit of %d exceeded: %d", e.Limit, e.Max, e.Actual)
+}
+
+// LimitUsage is the usage of the limits of an entity, reported for the CRDT
+// with the most elements and the CRDT with the largest size.
+type LimitUsage struct {
+	Elements    int
+	MaxElements int
+	Size        int
+	MaxSize     int
+}
+
+// LimitUsageFunc is called with the limit usage of an entity after each
+// command.
+type LimitUsageFunc func(id EntityID, usage LimitUsage)
+
+// sizeGuard enforces limits for the CRDTs of an entity and records the
+// first rejected change.
+type sizeGuard struct {
+	limits   Limits
+	exceeded error
+}
+
+// guarded is implemented by CRDTs that enforce limits.
+type guarded interface {
+	setSizeGuard(g *sizeGuard)
+}
+
+// check returns a LimitError if a CRDT is not allowed to have the number of
+// elements and the size returned by size. A rejection is recorded.
+func (g *sizeGuard) check(elements int, size func() int) error {
+	if g == nil {
+		return nil
+	}
+	if g.limits.MaxElements > 0 && elements > g.limits.MaxElements {
+		return g.reject(&LimitError{Limit: "elements", Max: g.limits.MaxElements, Actual: elements})
+	}
+	if g.limits.MaxSize > 0 {
+		if s := size(); s > g.limits.MaxSize {
+			return g.reject(&LimitError{Limit: "size", Max: g.limits.MaxSize, Actual: s})
+		}
+	}
+	return nil
+}
+
+func (g *sizeGuard) reject(err error) error {
+	if g.exceeded == nil {
+		g.exceeded = err
+	}
+	return err
+}
+
+// err returns the first rejection recorded and resets it.
+func (g *sizeGuard) err() error {
+	if g == nil {
+		return nil
+	}
+	err := g.exceeded
+	g.exceeded = nil
+	return err
+}
+
+func sizeOf(values map[uint64]*any.Any) int {
+	size := 0
+	for _, v := range values {
+		size += proto.Size(v)
+	}
+	return size
+}
+
+func (s *GSet) setSizeGuard(g *sizeGuard) {
+	s.guard = g
+}
+
+func (s *ORSet) setSizeGuard(g *sizeGuard) {
+	s.guard = g
+}
+
+func (r *LWWRegister) setSizeGuard(g *sizeGuard) {
+	r.guard = g
+}
+
+func (m *ORMap) setSizeGuard(g *sizeGuard) {
+	m.guard = g
+	for _, e := range m.value {
+		if v, ok := e.Value.(guarded); ok {
+			v.setSizeGuard(g)
+		}
+	}
+}
+
+func (m *LWWMap) setSizeGuard(g *sizeGuard) {
+	m.m.setSizeGuard(g)
+}
+
+func (r *MVRegister) setSizeGuard(g *sizeGuard) {
+	r.set.setSizeGuard(g)
+}
+
+// usageOf returns the limit usage of a CRDT and the CRDTs it contains.
+func usageOf(c CRDT, limits Limits) LimitUsage {
+	u := LimitUsage{MaxElements: limits.MaxElements, MaxSize: limits.MaxSize}
+	u.add(c)
+	return u
+}
+
+func (u *LimitUsage) add(c CRDT) {
+	switch t := c.(type) {
+	case *GSet:
+		u.max(len(t.value), t.size)
+	case *ORSet:
+		u.max(len(t.value), t.size)
+	case *LWWRegister:
+		u.max(0, proto.Size(t.value))
+	case *LWWMap:
+		u.add(t.m)
+	case *MVRegister:
+		u.add(t.set)
+	case *ORMap:
+		for _, e := range t.value {
+			u.add(e.Value)
+		}
+		u.max(len(t.value), t.size)
+	}
+}
+
+func (u *LimitUsage) max(elements, size int) {
+	if elements > u.Elements {
+		u.Elements = elements
+	}
+	if size > u.Size {
+		u.Size = size
+	}
+}
@@ -0,0 +1,221 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+func TestLimits(t *testing.T) {
+	t.Run("an add exceeding the element limit is rejected", func(t *testing.T) {
+		g := &sizeGuard{limits: Limits{MaxElements: 2}}
+		s := NewORSet()
+		s.setSizeGuard(g)
+		s.Add(encoding.String("one"))
+		s.Add(encoding.String("two"))
+		if err := g.err(); err != nil {
+			t.Fatal(err)
+		}
+		s.Add(encoding.String("three"))
+		if s.Size() != 2 {
+			t.Fatalf("s.Size(): %v; want: %v", s.Size(), 2)
+		}
+		if l := len(s.Delta().GetOrset().GetAdded()); l != 2 {
+			t.Fatalf("delta added length: %v; want: %v", l, 2)
+		}
+		var limitErr *LimitError
+		if err := g.err(); !errors.As(err, &limitErr) || limitErr.Limit != "elements" {
+			t.Fatalf("g.err(): %v; want: an elements limit error", err)
+		}
+	})
+
+	t.Run("rejected changes are returned by the error returning variants", func(t *testing.T) {
+		g := &sizeGuard{limits: Limits{MaxSize: 30}}
+		tooLarge := encoding.String("a value too large for the limit")
+		s := NewGSet()
+		s.setSizeGuard(g)
+		r := NewLWWRegister(nil)
+		r.setSizeGuard(g)
+		mv := NewMVRegister()
+		mv.setSizeGuard(g)
+		for name, err := range map[string]error{
+			"GSet.TryAdd":                 s.TryAdd(tooLarge),
+			"LWWRegister.TrySetWithClock": r.TrySetWithClock(tooLarge, Default, 0),
+			"MVRegister.TrySet":           mv.TrySet(tooLarge),
+		} {
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || limitErr.Limit != "size" {
+				t.Fatalf("%s: %v; want: a size limit error", name, err)
+			}
+		}
+		if err := s.TryAdd(encoding.String("s")); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("nested values of a map get the size guard", func(t *testing.T) {
+		g := &sizeGuard{limits: Limits{MaxSize: 40}}
+		m := NewORMap()
+		m.setSizeGuard(g)
+		r, err := m.GetOrCreateLWWRegister(encoding.String("r"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Set(encoding.String("a value too large for the limit"))
+		if r.Value() != nil {
+			t.Fatalf("r.Value(): %v; want: nil", r.Value())
+		}
+		var limitErr *LimitError
+		if err := g.err(); !errors.As(err, &limitErr) || limitErr.Limit != "size" {
+			t.Fatalf("g.err(): %v; want: a size limit error", err)
+		}
+	})
+
+	t.Run("a rejected value of a map is reported by GetOrCreate", func(t *testing.T) {
+		g := &sizeGuard{limits: Limits{MaxElements: 1}}
+		m := NewORMap()
+		m.setSizeGuard(g)
+		if _, err := m.GetOrCreateGSet(encoding.String("one")); err != nil {
+			t.Fatal(err)
+		}
+		s, err := m.GetOrCreateGSet(encoding.String("two"))
+		var limitErr *LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != "elements" {
+			t.Fatalf("err: %v; want: an elements limit error", err)
+		}
+		if s != nil {
+			t.Fatalf("s: %v; want: nil", s)
+		}
+		if m.Size() != 1 {
+			t.Fatalf("m.Size(): %v; want: %v", m.Size(), 1)
+		}
+	})
+
+	t.Run("the size is kept across changes and deltas", func(t *testing.T) {
+		s := NewORSet()
+		one, two := encoding.String("one"), encoding.String("two")
+		s.Add(one)
+		s.Add(two)
+		s.Remove(one)
+		if err := s.applyDelta(&entity.CrdtDelta{Delta: &entity.CrdtDelta_Orset{Orset: &entity.ORSetDelta{
+			Added:   []*any.Any{encoding.String("three")},
+			Removed: []*any.Any{two},
+		}}}); err != nil {
+			t.Fatal(err)
+		}
+		if want := sizeOf(s.value); s.size != want {
+			t.Fatalf("s.size: %v; want: %v", s.size, want)
+		}
+		m := NewORMap()
+		m.Set(one, NewFlag())
+		m.Set(one, NewFlag())
+		m.Set(two, NewFlag())
+		m.Delete(two)
+		if want := proto.Size(one); m.size != want {
+			t.Fatalf("m.size: %v; want: %v", m.size, want)
+		}
+	})
+
+	t.Run("usage reports the largest CRDT", func(t *testing.T) {
+		m := NewORMap()
+		s, _ := m.GetOrCreateGSet(encoding.String("s"))
+		s.Add(encoding.String("one"))
+		s.Add(encoding.String("two"))
+		s.Add(encoding.String("three"))
+		u := usageOf(m, Limits{MaxElements: 10})
+		if u.Elements != 3 || u.MaxElements != 10 {
+			t.Fatalf("usage: %+v; want: 3 of 10 elements", u)
+		}
+	})
+}
+
+type limitedEntity struct {
+	set *GSet
+}
+
+func (e *limitedEntity) HandleCommand(ctx *CommandContext, name string, msg proto.Message) (*any.Any, error) {
+	for _, v := range strings.Split(encoding.DecodeString(msg.(*any.Any)), ",") {
+		if err := e.set.TryAdd(encoding.String(v)); err != nil {
+			return nil, err
+		}
+	}
+	return encoding.Empty, nil
+}
+
+func (e *limitedEntity) Default(ctx *Context) (CRDT, error) {
+	return NewGSet(), nil
+}
+
+func (e *limitedEntity) Set(ctx *Context, state CRDT) error {
+	e.set = state.(*GSet)
+	return nil
+}
+
+func TestLimitedEntity(t *testing.T) {
+	var usage LimitUsage
+	e := &Entity{
+		ServiceName: "test.Limited",
+		EntityFunc: func(id EntityID) EntityHandler {
+			return &limitedEntity{}
+		},
+	}
+	e.Options(WithLimits(Limits{MaxElements: 2}), WithLimitUsage(func(id EntityID, u LimitUsage) {
+		usage = u
+	}))
+	s := NewServer()
+	if err := s.Register(e); err != nil {
+		t.Fatal(err)
+	}
+	r := &runner{stream: &sendStream{}}
+	if err := s.handleInit(&entity.CrdtInit{ServiceName: "test.Limited", EntityId: "e"}, r); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range []string{"one", "two,three", "one"} {
+		payload, err := encoding.MarshalAny(encoding.String(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.handleCommand(&protocol.Command{EntityId: "e", Id: int64(i), Payload: payload}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent := r.stream.(*sendStream).sent
+	if f := sent[0].GetReply().GetClientAction().GetFailure(); f != nil {
+		t.Fatalf("first command failed: %v", f)
+	}
+	if f := sent[1].GetReply().GetClientAction().GetFailure(); f == nil {
+		t.Fatal("second command should have failed")
+	}
+	if a := sent[1].GetReply().GetStateAction(); a != nil {
+		t.Fatalf("state action of the failed command: %v; want: nil", a)
+	}
+	// the third command changes nothing, and must not carry the changes
+	// of the rejected second command.
+	if a := sent[2].GetReply().GetStateAction(); a != nil {
+		t.Fatalf("state action of the third command: %v; want: nil", a)
+	}
+	if usage.MaxElements != 2 {
+		t.Fatalf("usage: %+v; want: a maximum of 2 elements", usage)
+	}
+}
@@ -0,0 +1,164 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"fmt"
+
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// LWWMap, or Last-Write-Wins Map, is a map of values where concurrent
+// updates of a key are resolved by the clock of each update. It is composed
+// of an ORMap with a LWWRegister per key and is replicated as such. A state
+// received for an LWWMap therefore is an ORMap, to be accessed with
+// AsLWWMap.
+type LWWMap struct {
+	m      *ORMap
+	source ClockSource
+}
+
+var _ CRDT = (*LWWMap)(nil)
+
+func NewLWWMap() *LWWMap {
+	return &LWWMap{m: NewORMap()}
+}
+
+// AsLWWMap returns an LWWMap view of a CRDT being an LWWMap or an ORMap of
+// LWWRegisters.
+func AsLWWMap(c CRDT) (*LWWMap, error) {
+	switch t := c.(type) {
+	case *LWWMap:
+		return t, nil
+	case *ORMap:
+		for _, e := range t.Entries() {
+			if _, ok := e.Value.(*LWWRegister); !ok {
+				return nil, &TypeMismatchError{Key: e.Key, Expected: "LWWRegister", Actual: e.Value}
+			}
+		}
+		return &LWWMap{m: t}, nil
+	default:
+		return nil, fmt.Errorf("unable to use CRDT as LWWMap: %T", c)
+	}
+}
+
+// ORMap returns the underlying ORMap.
+func (m *LWWMap) ORMap() *ORMap {
+	return m.m
+}
+
+func (m *LWWMap) Size() int {
+	return m.m.Size()
+}
+
+func (m *LWWMap) HasKey(key *any.Any) bool {
+	return m.m.HasKey(key)
+}
+
+func (m *LWWMap) Keys() []*any.Any {
+	return m.m.Keys()
+}
+
+// Get returns the value for key, nil if the key is absent.
+func (m *LWWMap) Get(key *any.Any) *any.Any {
+	if r, ok := m.m.Get(key).(*LWWRegister); ok {
+		return r.Value()
+	}
+	return nil
+}
+
+// Set sets the value for key using the clock of the maps clock source, or
+// the default clock if there is none. If the clock source fails, the
+// failure is logged and the default clock is used.
+func (m *LWWMap) Set(key *any.Any, value *any.Any) {
+	clock, customClockValue := clockOrDefault(m.clockSource(), m.m.entityCtx)
+	m.SetWithClock(key, value, clock, customClockValue)
+}
+
+// TrySet is Set but returns the error of a failed clock source, without
+// setting the value, or a LimitError if key or value has been rejected by
+// the limits of the entity.
+func (m *LWWMap) TrySet(key *any.Any, value *any.Any) error {
+	clock, customClockValue, err := clockFor(m.clockSource(), m.m.entityCtx)
+	if err != nil {
+		return err
+	}
+	return m.TrySetWithClock(key, value, clock, customClockValue)
+}
+
+// SetWithClock sets the value for key using the clock given. The custom
+// clock value is used if the clock selected is a custom clock.
+func (m *LWWMap) SetWithClock(key *any.Any, value *any.Any, c Clock, customClockValue int64) {
+	_ = m.TrySetWithClock(key, value, c, customClockValue)
+}
+
+// TrySetWithClock is SetWithClock but returns a LimitError if key or value
+// has been rejected by the limits of the entity.
+func (m *LWWMap) TrySetWithClock(key *any.Any, value *any.Any, c Clock, customClockValue int64) error {
+	if r, ok := m.m.Get(key).(*LWWRegister); ok {
+		return r.TrySetWithClock(value, c, customClockValue)
+	}
+	if err := m.m.guard.check(0, func() int { return proto.Size(value) }); err != nil {
+		return err
+	}
+	return m.m.set(key, NewLWWRegisterWithClock(value, c, customClockValue))
+}
+
+func (m *LWWMap) Delete(key *any.Any) {
+	m.m.Delete(key)
+}
+
+func (m *LWWMap) Clear() {
+	m.m.Clear()
+}
+
+// Entries returns the keys with their values.
+func (m *LWWMap) Entries() []*LWWMapEntry {
+	entries := make([]*LWWMapEntry, 0, m.m.Size())
+	for _, e := range m.m.Entries() {
+		if r, ok := e.Value.(*LWWRegister); ok {
+			entries = append(entries, &LWWMapEntry{Key: e.Key, Value: r.Value()})
+		}
+	}
+	return entries
+}
+
+type LWWMapEntry struct {
+	Key   *any.Any
+	Value *any.Any
+}
+
+func (m *LWWMap) Delta() *entity.CrdtDelta {
+	return m.m.Delta()
+}
+
+func (m *LWWMap) HasDelta() bool {
+	return m.m.HasDelta()
+}
+
+func (m *LWWMap) SetKeyCanonicalizer(c KeyCanonicalizer) {
+	m.m.SetKeyCanonicalizer(c)
+}
+
+func (m *LWWMap) resetDelta() {
+	m.m.resetDelta()
+}
+
+func (m *LWWMap) applyDelta(delta *entity.CrdtDelta) error {
+	return m.m.applyDelta(delta)
+}
@@ -0,0 +1,84 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestLWWMap(t *testing.T) {
+	t.Run("should set and update values", func(t *testing.T) {
+		m := NewLWWMap()
+		m.Set(encoding.String("k"), encoding.String("one"))
+		delta := encDecDelta(m.Delta())
+		m.resetDelta()
+		if l := len(delta.GetOrmap().GetAdded()); l != 1 {
+			t.Fatalf("delta added length: %v; want: %v", l, 1)
+		}
+		m.SetWithClock(encoding.String("k"), encoding.String("two"), Custom, 7)
+		if v := encoding.DecodeString(m.Get(encoding.String("k"))); v != "two" {
+			t.Fatalf("m.Get(k): %v; want: %v", v, "two")
+		}
+		delta = encDecDelta(m.Delta())
+		m.resetDelta()
+		updated := delta.GetOrmap().GetUpdated()
+		if l := len(updated); l != 1 {
+			t.Fatalf("delta updated length: %v; want: %v", l, 1)
+		}
+		if c := updated[0].GetDelta().GetLwwregister().GetCustomClockValue(); c != 7 {
+			t.Fatalf("custom clock value: %v; want: %v", c, 7)
+		}
+		m.Delete(encoding.String("k"))
+		if m.Size() != 0 {
+			t.Fatalf("m.Size(): %v; want: %v", m.Size(), 0)
+		}
+	})
+
+	t.Run("should be nested in an ORMap and read back from its state", func(t *testing.T) {
+		m := NewORMap()
+		lm, err := m.GetOrCreateLWWMap(encoding.String("settings"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lm.Set(encoding.String("theme"), encoding.String("dark"))
+		c, err := NewFromJSON(mustMarshalJSON(t, m))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.(*ORMap).LWWMap(encoding.String("settings"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := got.Get(encoding.String("theme")); !proto.Equal(v, encoding.String("dark")) {
+			t.Fatalf("got.Get(theme): %v; want: %v", v, encoding.String("dark"))
+		}
+		if again, _ := c.(*ORMap).LWWMap(encoding.String("settings")); again != got {
+			t.Fatal("LWWMap() should return the same view")
+		}
+	})
+}
+
+func mustMarshalJSON(t *testing.T, c CRDT) []byte {
+	t.Helper()
+	data, err := MarshalJSON(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 
 	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
 )
 
@@ -38,6 +39,9 @@ type LWWRegister struct {
 	delta            lwwRegisterDelta
 	clock            Clock
 	customClockValue int64
+	guard            *sizeGuard
+	source           ClockSource
+	entityCtx        *Context
 
 	hasDelta bool
 }
@@ -64,13 +68,37 @@ func (r *LWWRegister) Value() *any.Any {
 	return r.value
 }
 
+// Set sets the value using the clock of the registers clock source, or
+// the default clock if there is none. If the clock source fails, the
+// failure is logged and the default clock is used.
 func (r *LWWRegister) Set(x *any.Any) {
-	r.SetWithClock(x, Default, 0)
+	clock, value := clockOrDefault(r.clockSource(), r.entityCtx)
+	r.SetWithClock(x, clock, value)
+}
+
+// TrySet is Set but returns the error of a failed clock source, without
+// setting the value, or a LimitError if x has been rejected by the limits
+// of the entity.
+func (r *LWWRegister) TrySet(x *any.Any) error {
+	clock, value, err := clockFor(r.clockSource(), r.entityCtx)
+	if err != nil {
+		return err
+	}
+	return r.TrySetWithClock(x, clock, value)
 }
 
 // SetWithClock uses the custom clock value to use if the clock selected
 // is a custom clock. This is ignored if the clock is not a custom clock.
 func (r *LWWRegister) SetWithClock(x *any.Any, c Clock, customClockValue int64) {
+	_ = r.TrySetWithClock(x, c, customClockValue)
+}
+
+// TrySetWithClock is SetWithClock but returns a LimitError if x has been
+// rejected by the limits of the entity.
+func (r *LWWRegister) TrySetWithClock(x *any.Any, c Clock, customClockValue int64) error {
+	if err := r.guard.check(0, func() int { return proto.Size(x) }); err != nil {
+		return err
+	}
 	r.value = x
 	r.clock = c
 	r.customClockValue = 0
@@ -82,6 +110,7 @@ func (r *LWWRegister) SetWithClock(x *any.Any, c Clock, customClockValue int64)
 		clock:            c,
 		customClockValue: r.customClockValue,
 	}
+	return nil
 }
 
 func (r *LWWRegister) Delta() *entity.CrdtDelta {
@@ -0,0 +1,117 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"fmt"
+
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// MVRegister, or Multi-Value Register, is a register that keeps all values
+// set concurrently. Setting a value replaces all values observed, while
+// values set concurrently on other nodes are kept, until a later set
+// replaces them. It is composed of an ORSet and is replicated as such.
+// A state received for an MVRegister therefore is an ORSet, to be accessed
+// with AsMVRegister.
+type MVRegister struct {
+	set *ORSet
+}
+
+var _ CRDT = (*MVRegister)(nil)
+
+func NewMVRegister() *MVRegister {
+	return &MVRegister{set: NewORSet()}
+}
+
+// AsMVRegister returns an MVRegister view of a CRDT being an MVRegister or
+// an ORSet.
+func AsMVRegister(c CRDT) (*MVRegister, error) {
+	switch t := c.(type) {
+	case *MVRegister:
+		return t, nil
+	case *ORSet:
+		return &MVRegister{set: t}, nil
+	default:
+		return nil, fmt.Errorf("unable to use CRDT as MVRegister: %T", c)
+	}
+}
+
+// ORSet returns the underlying ORSet.
+func (r *MVRegister) ORSet() *ORSet {
+	return r.set
+}
+
+// Values returns all values of the register.
+func (r *MVRegister) Values() []*any.Any {
+	return r.set.Value()
+}
+
+// Value returns the value of the register and true if there is exactly
+// one value.
+func (r *MVRegister) Value() (*any.Any, bool) {
+	if r.set.Size() != 1 {
+		return nil, false
+	}
+	return r.set.Value()[0], true
+}
+
+// Conflicted returns true if the register has concurrently set values.
+func (r *MVRegister) Conflicted() bool {
+	return r.set.Size() > 1
+}
+
+// Set replaces all values observed by value.
+func (r *MVRegister) Set(value *any.Any) {
+	_ = r.TrySet(value)
+}
+
+// TrySet is Set but returns a LimitError if value has been rejected by the
+// limits of the entity.
+func (r *MVRegister) TrySet(value *any.Any) error {
+	if err := r.set.guard.check(1, func() int { return proto.Size(value) }); err != nil {
+		return err
+	}
+	r.set.Clear()
+	return r.set.TryAdd(value)
+}
+
+// Clear removes all values observed.
+func (r *MVRegister) Clear() {
+	r.set.Clear()
+}
+
+func (r *MVRegister) Delta() *entity.CrdtDelta {
+	return r.set.Delta()
+}
+
+func (r *MVRegister) HasDelta() bool {
+	return r.set.HasDelta()
+}
+
+func (r *MVRegister) SetKeyCanonicalizer(c KeyCanonicalizer) {
+	r.set.SetKeyCanonicalizer(c)
+}
+
+func (r *MVRegister) resetDelta() {
+	r.set.resetDelta()
+}
+
+func (r *MVRegister) applyDelta(delta *entity.CrdtDelta) error {
+	return r.set.applyDelta(delta)
+}
@@ -0,0 +1,61 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+)
+
+func TestMVRegister(t *testing.T) {
+	t.Run("should replace its value", func(t *testing.T) {
+		r := NewMVRegister()
+		r.Set(encoding.String("one"))
+		r.resetDelta()
+		r.Set(encoding.String("two"))
+		if v, ok := r.Value(); !ok || encoding.DecodeString(v) != "two" {
+			t.Fatalf("r.Value(): %v, %v; want: %v", v, ok, "two")
+		}
+		delta := encDecDelta(r.Delta())
+		if !delta.GetOrset().GetCleared() {
+			t.Fatal("delta should be cleared")
+		}
+		if l := len(delta.GetOrset().GetAdded()); l != 1 {
+			t.Fatalf("delta added length: %v; want: %v", l, 1)
+		}
+	})
+
+	t.Run("should be read back from an ORSet state", func(t *testing.T) {
+		m := NewORMap()
+		r, err := m.GetOrCreateMVRegister(encoding.String("r"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Set(encoding.String("v"))
+		c, err := NewFromJSON(mustMarshalJSON(t, m))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.(*ORMap).MVRegister(encoding.String("r"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, ok := got.Value(); !ok || encoding.DecodeString(v) != "v" {
+			t.Fatalf("Value(): %v, %v; want: %v", v, ok, "v")
+		}
+	})
+}
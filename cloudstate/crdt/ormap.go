@@ -19,6 +19,7 @@ import (
 	"fmt"
 
 	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
 )
 
@@ -29,7 +30,11 @@ import (
 // are merged together.
 type ORMap struct {
 	value map[uint64]*ORMapEntry
-	delta orMapDelta
+	// size is the encoded size of the keys.
+	size      int
+	delta     orMapDelta
+	guard     *sizeGuard
+	entityCtx *Context
 	*anyHasher
 }
 
@@ -95,37 +100,51 @@ func (m *ORMap) Get(key *any.Any) CRDT {
 }
 
 func (m *ORMap) Set(key *any.Any, value CRDT) {
+	_ = m.set(key, value)
+}
+
+// set sets the value at key and returns a LimitError if the key has been
+// rejected by the size guard.
+func (m *ORMap) set(key *any.Any, value CRDT) error {
 	k := m.hashAny(key)
+	if _, has := m.value[k]; !has {
+		if err := m.guard.check(len(m.value)+1, func() int { return m.size + proto.Size(key) }); err != nil {
+			return err
+		}
+	}
+	m.adopt(value)
 	// from ref. impl: Setting an existing Key to a new value
 	// can have unintended effects, as the old value may end
 	// up being merged with the new.
-	if _, has := m.value[k]; has {
+	if e, has := m.value[k]; has {
 		if _, has := m.delta.added[k]; !has {
-			m.delta.removed[k] = key
+			m.delta.removed[k] = e.Key
 		}
 	}
-	m.value[k] = &ORMapEntry{
+	m.put(k, &ORMapEntry{
 		Key:   key,
 		Value: value,
-	}
+	})
 	m.delta.added[k] = key
+	return nil
 }
 
 func (m *ORMap) Delete(key *any.Any) {
 	k := m.hashAny(key)
-	if _, has := m.value[k]; !has {
+	entry, has := m.value[k]
+	if !has {
 		return
 	}
 	if len(m.value) == 1 {
 		m.Clear()
 		return
 	}
-	delete(m.value, k)
+	m.remove(k)
 	if _, has := m.delta.added[k]; has {
 		delete(m.delta.added, k)
 		return
 	}
-	m.delta.removed[k] = key
+	m.delta.removed[k] = entry.Key
 }
 
 func (m *ORMap) Clear() {
@@ -133,6 +152,7 @@ func (m *ORMap) Clear() {
 		return
 	}
 	m.value = make(map[uint64]*ORMapEntry)
+	m.size = 0
 	m.delta.clear()
 }
 
@@ -193,25 +213,29 @@ func (m *ORMap) applyDelta(delta *entity.CrdtDelta) error {
 	}
 	if d.GetCleared() {
 		m.value = make(map[uint64]*ORMapEntry)
+		m.size = 0
 	}
 	for _, r := range d.GetRemoved() {
-		delete(m.value, m.hashAny(r))
+		m.remove(m.hashAny(r))
 	}
 	for _, a := range d.Added {
 		var err error
-		value := m.Get(a.GetKey())
+		key := a.GetKey()
+		k := m.hashAny(key)
+		value := m.Get(key)
 		if value == nil {
 			if value, err = newFor(a.GetDelta()); err != nil {
 				return err
 			}
+			m.adopt(value)
 		}
 		if err := value.applyDelta(a.GetDelta()); err != nil {
 			return err
 		}
-		m.value[m.hashAny(a.GetKey())] = &ORMapEntry{
-			Key:   a.GetKey(),
+		m.put(k, &ORMapEntry{
+			Key:   key,
 			Value: value,
-		}
+		})
 	}
 	for _, u := range d.Updated {
 		if v, has := m.value[m.hashAny(u.GetKey())]; has {
@@ -231,3 +255,32 @@ func (m *ORMap) resetDelta() {
 	m.delta.added = make(map[uint64]*any.Any)
 	m.delta.removed = make(map[uint64]*any.Any)
 }
+
+// adopt sets the key canonicalizer, size guard and entity context of the
+// map on a value.
+func (m *ORMap) adopt(value CRDT) {
+	if c, ok := value.(keyCanonicalized); ok && m.canonicalize != nil {
+		c.SetKeyCanonicalizer(m.canonicalize)
+	}
+	if g, ok := value.(guarded); ok && m.guard != nil {
+		g.setSizeGuard(m.guard)
+	}
+	if c, ok := value.(clocked); ok && m.entityCtx != nil {
+		c.setEntityContext(m.entityCtx)
+	}
+}
+
+// put sets an entry and keeps the size of the keys.
+func (m *ORMap) put(k uint64, e *ORMapEntry) {
+	m.remove(k)
+	m.value[k] = e
+	m.size += proto.Size(e.Key)
+}
+
+// remove deletes an entry and keeps the size of the keys.
+func (m *ORMap) remove(k uint64) {
+	if old, has := m.value[k]; has {
+		m.size -= proto.Size(old.Key)
+		delete(m.value, k)
+	}
+}
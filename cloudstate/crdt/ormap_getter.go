@@ -21,12 +21,24 @@ import (
 	"github.com/golang/protobuf/ptypes/any"
 )
 
+// TypeMismatchError is returned by the ORMap accessors if the value
+// at a key is not of the requested CRDT type.
+type TypeMismatchError struct {
+	Key      *any.Any
+	Expected string
+	Actual   CRDT
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("value at key: %v is not of type %s but: %T", e.Key, e.Expected, e.Actual)
+}
+
 func (m *ORMap) Flag(key *any.Any) (*Flag, error) {
 	if v, has := m.value[m.hashAny(key)]; has {
 		if flag, ok := v.Value.(*Flag); ok {
 			return flag, nil
 		}
-		return nil, fmt.Errorf("value at key: %v is not of type Flag but: %+v", key, v)
+		return nil, &TypeMismatchError{Key: key, Expected: "Flag", Actual: v.Value}
 	}
 	return nil, nil
 }
@@ -36,7 +48,7 @@ func (m *ORMap) GCounter(key *any.Any) (*GCounter, error) {
 		if counter, ok := v.Value.(*GCounter); ok {
 			return counter, nil
 		}
-		return nil, fmt.Errorf("value at key: %v is not of type GCounter but: %+v", key, v)
+		return nil, &TypeMismatchError{Key: key, Expected: "GCounter", Actual: v.Value}
 	}
 	return nil, nil
 }
@@ -46,7 +58,7 @@ func (m *ORMap) GSet(key *any.Any) (*GSet, error) {
 		if set, ok := v.Value.(*GSet); ok {
 			return set, nil
 		}
-		return nil, fmt.Errorf("value at key: %v is not of type GSet but: %+v", key, v)
+		return nil, &TypeMismatchError{Key: key, Expected: "GSet", Actual: v.Value}
 	}
 	return nil, nil
 }
@@ -56,7 +68,7 @@ func (m *ORMap) LWWRegister(key *any.Any) (*LWWRegister, error) {
 		if r, ok := v.Value.(*LWWRegister); ok {
 			return r, nil
 		}
-		return nil, fmt.Errorf("value at key: %v is not of type LWWRegister but: %+v", key, v)
+		return nil, &TypeMismatchError{Key: key, Expected: "LWWRegister", Actual: v.Value}
 	}
 	return nil, nil
 }
@@ -66,7 +78,7 @@ func (m *ORMap) ORMap(key *any.Any) (*ORMap, error) {
 		if m, ok := v.Value.(*ORMap); ok {
 			return m, nil
 		}
-		return nil, fmt.Errorf("value at key: %v is not of type ORMap but: %+v", key, v)
+		return nil, &TypeMismatchError{Key: key, Expected: "ORMap", Actual: v.Value}
 	}
 	return nil, nil
 }
@@ -76,7 +88,7 @@ func (m *ORMap) ORSet(key *any.Any) (*ORSet, error) {
 		if set, ok := v.Value.(*ORSet); ok {
 			return set, nil
 		}
-		return nil, fmt.Errorf("value at key: %v is not of type ORSet but: %+v", key, v)
+		return nil, &TypeMismatchError{Key: key, Expected: "ORSet", Actual: v.Value}
 	}
 	return nil, nil
 }
@@ -86,7 +98,7 @@ func (m *ORMap) PNCounter(key *any.Any) (*PNCounter, error) {
 		if c, ok := v.Value.(*PNCounter); ok {
 			return c, nil
 		}
-		return nil, fmt.Errorf("value at key: %v is not of type PNCounter but: %+v", key, v)
+		return nil, &TypeMismatchError{Key: key, Expected: "PNCounter", Actual: v.Value}
 	}
 	return nil, nil
 }
@@ -96,7 +108,179 @@ func (m *ORMap) Vote(key *any.Any) (*Vote, error) {
 		if c, ok := v.Value.(*Vote); ok {
 			return c, nil
 		}
-		return nil, fmt.Errorf("value at key: %v is not of type Vote but: %+v", key, v)
+		return nil, &TypeMismatchError{Key: key, Expected: "Vote", Actual: v.Value}
 	}
 	return nil, nil
 }
+
+// GetOrCreateFlag returns the Flag at key or sets a new one if absent.
+func (m *ORMap) GetOrCreateFlag(key *any.Any) (*Flag, error) {
+	c, err := m.Flag(key)
+	if err != nil || c != nil {
+		return c, err
+	}
+	c = NewFlag()
+	if err := m.set(key, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetOrCreateGCounter returns the GCounter at key or sets a new one if absent.
+func (m *ORMap) GetOrCreateGCounter(key *any.Any) (*GCounter, error) {
+	c, err := m.GCounter(key)
+	if err != nil || c != nil {
+		return c, err
+	}
+	c = NewGCounter()
+	if err := m.set(key, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetOrCreateGSet returns the GSet at key or sets a new one if absent.
+func (m *ORMap) GetOrCreateGSet(key *any.Any) (*GSet, error) {
+	c, err := m.GSet(key)
+	if err != nil || c != nil {
+		return c, err
+	}
+	c = NewGSet()
+	if err := m.set(key, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetOrCreateLWWRegister returns the LWWRegister at key or sets a new one
+// with value if absent.
+func (m *ORMap) GetOrCreateLWWRegister(key *any.Any, value *any.Any) (*LWWRegister, error) {
+	c, err := m.LWWRegister(key)
+	if err != nil || c != nil {
+		return c, err
+	}
+	c = NewLWWRegister(value)
+	if err := m.set(key, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetOrCreateORMap returns the ORMap at key or sets a new one if absent.
+func (m *ORMap) GetOrCreateORMap(key *any.Any) (*ORMap, error) {
+	c, err := m.ORMap(key)
+	if err != nil || c != nil {
+		return c, err
+	}
+	c = NewORMap()
+	if err := m.set(key, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetOrCreateORSet returns the ORSet at key or sets a new one if absent.
+func (m *ORMap) GetOrCreateORSet(key *any.Any) (*ORSet, error) {
+	c, err := m.ORSet(key)
+	if err != nil || c != nil {
+		return c, err
+	}
+	c = NewORSet()
+	if err := m.set(key, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetOrCreatePNCounter returns the PNCounter at key or sets a new one if absent.
+func (m *ORMap) GetOrCreatePNCounter(key *any.Any) (*PNCounter, error) {
+	c, err := m.PNCounter(key)
+	if err != nil || c != nil {
+		return c, err
+	}
+	c = NewPNCounter()
+	if err := m.set(key, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetOrCreateVote returns the Vote at key or sets a new one if absent.
+func (m *ORMap) GetOrCreateVote(key *any.Any) (*Vote, error) {
+	c, err := m.Vote(key)
+	if err != nil || c != nil {
+		return c, err
+	}
+	c = NewVote()
+	if err := m.set(key, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LWWMap returns the LWWMap at key. A value received as ORMap is replaced
+// by its LWWMap view.
+func (m *ORMap) LWWMap(key *any.Any) (*LWWMap, error) {
+	if v, has := m.value[m.hashAny(key)]; has {
+		switch c := v.Value.(type) {
+		case *LWWMap:
+			return c, nil
+		case *ORMap:
+			lm, err := AsLWWMap(c)
+			if err != nil {
+				return nil, err
+			}
+			v.Value = lm
+			return lm, nil
+		}
+		return nil, &TypeMismatchError{Key: key, Expected: "LWWMap", Actual: v.Value}
+	}
+	return nil, nil
+}
+
+// MVRegister returns the MVRegister at key. A value received as ORSet is
+// replaced by its MVRegister view.
+func (m *ORMap) MVRegister(key *any.Any) (*MVRegister, error) {
+	if v, has := m.value[m.hashAny(key)]; has {
+		switch c := v.Value.(type) {
+		case *MVRegister:
+			return c, nil
+		case *ORSet:
+			r, err := AsMVRegister(c)
+			if err != nil {
+				return nil, err
+			}
+			v.Value = r
+			return r, nil
+		}
+		return nil, &TypeMismatchError{Key: key, Expected: "MVRegister", Actual: v.Value}
+	}
+	return nil, nil
+}
+
+// GetOrCreateLWWMap returns the LWWMap at key or sets a new one if absent.
+func (m *ORMap) GetOrCreateLWWMap(key *any.Any) (*LWWMap, error) {
+	c, err := m.LWWMap(key)
+	if err != nil || c != nil {
+		return c, err
+	}
+	c = NewLWWMap()
+	if err := m.set(key, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetOrCreateMVRegister returns the MVRegister at key or sets a new one if
+// absent.
+func (m *ORMap) GetOrCreateMVRegister(key *any.Any) (*MVRegister, error) {
+	c, err := m.MVRegister(key)
+	if err != nil || c != nil {
+		return c, err
+	}
+	c = NewMVRegister()
+	if err := m.set(key, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,90 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+)
+
+func TestORMapGetOrCreate(t *testing.T) {
+	t.Run("should create and add a value for an absent key", func(t *testing.T) {
+		m := NewORMap()
+		c, err := m.GetOrCreatePNCounter(encoding.String("c"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Increment(3)
+		if !m.HasKey(encoding.String("c")) {
+			t.Fatal("m has no 'c' key")
+		}
+		delta := encDecDelta(m.Delta())
+		m.resetDelta()
+		if l := len(delta.GetOrmap().GetAdded()); l != 1 {
+			t.Fatalf("delta added length: %v; want: %v", l, 1)
+		}
+		if v := delta.GetOrmap().GetAdded()[0].GetDelta().GetPncounter().GetChange(); v != 3 {
+			t.Fatalf("PNCounter.Change: %v; want: %v", v, 3)
+		}
+	})
+
+	t.Run("should return the existing value", func(t *testing.T) {
+		m := NewORMap()
+		s := NewORSet()
+		s.Add(encoding.String("one"))
+		m.Set(encoding.String("s"), s)
+		m.resetDelta()
+		got, err := m.GetOrCreateORSet(encoding.String("s"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != s {
+			t.Fatalf("GetOrCreateORSet(): %v; want: %v", got, s)
+		}
+		if m.HasDelta() {
+			t.Fatal("m should have no delta")
+		}
+	})
+
+	t.Run("should create a register with the initial value", func(t *testing.T) {
+		m := NewORMap()
+		r, err := m.GetOrCreateLWWRegister(encoding.String("r"), encoding.String("initial"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := encoding.DecodeString(r.Value()); v != "initial" {
+			t.Fatalf("r.Value(): %v; want: %v", v, "initial")
+		}
+	})
+
+	t.Run("should fail with a type mismatch error", func(t *testing.T) {
+		m := NewORMap()
+		m.Set(encoding.String("f"), NewFlag())
+		_, err := m.GetOrCreateVote(encoding.String("f"))
+		var mismatch *TypeMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("err: %v; want: %T", err, mismatch)
+		}
+		if mismatch.Expected != "Vote" {
+			t.Fatalf("mismatch.Expected: %v; want: %v", mismatch.Expected, "Vote")
+		}
+		if _, ok := mismatch.Actual.(*Flag); !ok {
+			t.Fatalf("mismatch.Actual: %T; want: %T", mismatch.Actual, &Flag{})
+		}
+	})
+}
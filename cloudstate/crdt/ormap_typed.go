@@ -0,0 +1,119 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"fmt"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+)
+
+// TypedORMap is a view on an ORMap with keys of type K and values of the CRDT
+// type V. Keys are encoded by a codec and the underlying ORMap produces the
+// deltas.
+type TypedORMap[K interface{}, V CRDT] struct {
+	m     *ORMap
+	codec encoding.Codec[K]
+}
+
+// ORMapOf returns a TypedORMap for the given ORMap.
+func ORMapOf[K interface{}, V CRDT](m *ORMap, codec encoding.Codec[K]) *TypedORMap[K, V] {
+	return &TypedORMap[K, V]{m: m, codec: codec}
+}
+
+// ORMap returns the underlying ORMap.
+func (m *TypedORMap[K, V]) ORMap() *ORMap {
+	return m.m
+}
+
+func (m *TypedORMap[K, V]) Size() int {
+	return m.m.Size()
+}
+
+func (m *TypedORMap[K, V]) HasKey(key K) (bool, error) {
+	k, err := m.codec.Encode(key)
+	if err != nil {
+		return false, err
+	}
+	return m.m.HasKey(k), nil
+}
+
+// Get returns the value for the key and whether the key is present.
+func (m *TypedORMap[K, V]) Get(key K) (V, bool, error) {
+	var zero V
+	k, err := m.codec.Encode(key)
+	if err != nil {
+		return zero, false, err
+	}
+	value := m.m.Get(k)
+	if value == nil {
+		return zero, false, nil
+	}
+	v, ok := value.(V)
+	if !ok {
+		return zero, false, &TypeMismatchError{Key: k, Expected: fmt.Sprintf("%T", zero), Actual: value}
+	}
+	return v, true, nil
+}
+
+// GetOrCreate returns the value for the key. If the key is absent, the value
+// returned by create is set for the key.
+func (m *TypedORMap[K, V]) GetOrCreate(key K, create func() V) (V, error) {
+	v, ok, err := m.Get(key)
+	if err != nil || ok {
+		return v, err
+	}
+	v = create()
+	if err := m.Set(key, v); err != nil {
+		var zero V
+		return zero, err
+	}
+	return v, nil
+}
+
+func (m *TypedORMap[K, V]) Set(key K, value V) error {
+	k, err := m.codec.Encode(key)
+	if err != nil {
+		return err
+	}
+	return m.m.set(k, value)
+}
+
+func (m *TypedORMap[K, V]) Delete(key K) error {
+	k, err := m.codec.Encode(key)
+	if err != nil {
+		return err
+	}
+	m.m.Delete(k)
+	return nil
+}
+
+func (m *TypedORMap[K, V]) Clear() {
+	m.m.Clear()
+}
+
+// Keys returns the decoded keys of the map.
+func (m *TypedORMap[K, V]) Keys() ([]K, error) {
+	keys := make([]K, 0, len(m.m.value))
+	for _, e := range m.m.value {
+		k, err := m.codec.Decode(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
@@ -0,0 +1,78 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+)
+
+func TestTypedORMap(t *testing.T) {
+	t.Run("should set and get typed values", func(t *testing.T) {
+		m := ORMapOf[string, *PNCounter](NewORMap(), encoding.StringCodec)
+		c := NewPNCounter()
+		c.Increment(5)
+		if err := m.Set("apples", c); err != nil {
+			t.Fatal(err)
+		}
+		got, ok, err := m.Get("apples")
+		if err != nil || !ok {
+			t.Fatalf("m.Get(): %v, %v", ok, err)
+		}
+		if got.Value() != 5 {
+			t.Fatalf("got.Value(): %v; want: %v", got.Value(), 5)
+		}
+		if _, ok, _ := m.Get("pears"); ok {
+			t.Fatal("m.Get() should not have found pears")
+		}
+		keys, err := m.Keys()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 1 || keys[0] != "apples" {
+			t.Fatalf("m.Keys(): %v; want: %v", keys, []string{"apples"})
+		}
+		delta := encDecDelta(m.ORMap().Delta())
+		if alen := len(delta.GetOrmap().GetAdded()); alen != 1 {
+			t.Fatalf("len(GetAdded()): %v; want: %v", alen, 1)
+		}
+	})
+
+	t.Run("should fail for a value of a different type", func(t *testing.T) {
+		raw := NewORMap()
+		raw.Set(encoding.String("apples"), NewGCounter())
+		m := ORMapOf[string, *PNCounter](raw, encoding.StringCodec)
+		if _, _, err := m.Get("apples"); err == nil {
+			t.Fatal("m.Get() should have failed")
+		}
+	})
+
+	t.Run("should delete a key", func(t *testing.T) {
+		m := ORMapOf[string, *Flag](NewORMap(), encoding.StringCodec)
+		_ = m.Set("one", NewFlag())
+		_ = m.Set("two", NewFlag())
+		if err := m.Delete("one"); err != nil {
+			t.Fatal(err)
+		}
+		if has, _ := m.HasKey("one"); has {
+			t.Fatal("map should not have key one")
+		}
+		if m.Size() != 1 {
+			t.Fatalf("m.Size(): %v; want: %v", m.Size(), 1)
+		}
+	})
+}
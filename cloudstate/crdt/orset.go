@@ -19,6 +19,7 @@ import (
 	"fmt"
 
 	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
 )
 
@@ -33,6 +34,9 @@ type ORSet struct {
 	added   map[uint64]*any.Any
 	removed map[uint64]*any.Any
 	cleared bool
+	// size is the encoded size of the values.
+	size  int
+	guard *sizeGuard
 	*anyHasher
 }
 
@@ -53,28 +57,39 @@ func (s *ORSet) Size() int {
 }
 
 func (s *ORSet) Add(a *any.Any) {
+	_ = s.TryAdd(a)
+}
+
+// TryAdd adds a and returns a LimitError if a has been rejected by the
+// limits of the entity.
+func (s *ORSet) TryAdd(a *any.Any) error {
 	h := s.hashAny(a)
 	if _, ok := s.value[h]; ok {
-		return
+		return nil
+	}
+	if err := s.guard.check(len(s.value)+1, func() int { return s.size + proto.Size(a) }); err != nil {
+		return err
 	}
 	if _, ok := s.removed[h]; ok {
 		delete(s.removed, h)
 	} else {
 		s.added[h] = a
 	}
-	s.value[h] = a
+	s.put(h, a)
+	return nil
 }
 
 func (s *ORSet) Remove(a *any.Any) {
 	h := s.hashAny(a)
-	if _, ok := s.value[h]; !ok {
+	a, ok := s.value[h]
+	if !ok {
 		return
 	}
 	if len(s.value) == 1 {
 		s.Clear()
 		return
 	}
-	delete(s.value, h)
+	s.remove(h)
 	if _, ok := s.added[h]; ok {
 		delete(s.added, h)
 	} else {
@@ -84,6 +99,7 @@ func (s *ORSet) Remove(a *any.Any) {
 
 func (s *ORSet) Clear() {
 	s.value = make(map[uint64]*any.Any)
+	s.size = 0
 	s.added = make(map[uint64]*any.Any)
 	s.removed = make(map[uint64]*any.Any)
 	s.cleared = true
@@ -148,15 +164,30 @@ func (s *ORSet) applyDelta(delta *entity.CrdtDelta) error {
 	}
 	if d.GetCleared() {
 		s.value = make(map[uint64]*any.Any)
+		s.size = 0
 	}
 	for _, r := range d.GetRemoved() {
-		delete(s.value, s.hashAny(r))
+		s.remove(s.hashAny(r))
 	}
 	for _, a := range d.GetAdded() {
-		h := s.hashAny(a)
-		if _, ok := s.value[h]; !ok {
-			s.value[h] = a
+		if h := s.hashAny(a); s.value[h] == nil {
+			s.put(h, a)
 		}
 	}
 	return nil
 }
+
+// put sets a value and keeps the size of the values.
+func (s *ORSet) put(h uint64, a *any.Any) {
+	s.remove(h)
+	s.value[h] = a
+	s.size += proto.Size(a)
+}
+
+// remove deletes a value and keeps the size of the values.
+func (s *ORSet) remove(h uint64) {
+	if old, ok := s.value[h]; ok {
+		s.size -= proto.Size(old)
+		delete(s.value, h)
+	}
+}
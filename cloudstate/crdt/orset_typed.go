@@ -0,0 +1,84 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+)
+
+// TypedORSet is a view on an ORSet with elements of type T. Elements are
+// encoded by a codec and the underlying ORSet produces the deltas.
+type TypedORSet[T interface{}] struct {
+	set   *ORSet
+	codec encoding.Codec[T]
+}
+
+// ORSetOf returns a TypedORSet for the given ORSet.
+func ORSetOf[T interface{}](s *ORSet, codec encoding.Codec[T]) *TypedORSet[T] {
+	return &TypedORSet[T]{set: s, codec: codec}
+}
+
+// ORSet returns the underlying ORSet.
+func (s *TypedORSet[T]) ORSet() *ORSet {
+	return s.set
+}
+
+func (s *TypedORSet[T]) Size() int {
+	return s.set.Size()
+}
+
+func (s *TypedORSet[T]) Add(v T) error {
+	a, err := s.codec.Encode(v)
+	if err != nil {
+		return err
+	}
+	return s.set.TryAdd(a)
+}
+
+func (s *TypedORSet[T]) Remove(v T) error {
+	a, err := s.codec.Encode(v)
+	if err != nil {
+		return err
+	}
+	s.set.Remove(a)
+	return nil
+}
+
+func (s *TypedORSet[T]) Contains(v T) (bool, error) {
+	a, err := s.codec.Encode(v)
+	if err != nil {
+		return false, err
+	}
+	_, ok := s.set.value[s.set.hashAny(a)]
+	return ok, nil
+}
+
+func (s *TypedORSet[T]) Clear() {
+	s.set.Clear()
+}
+
+// Value returns the decoded elements of the set.
+func (s *TypedORSet[T]) Value() ([]T, error) {
+	val := make([]T, 0, len(s.set.value))
+	for _, a := range s.set.value {
+		v, err := s.codec.Decode(a)
+		if err != nil {
+			return nil, err
+		}
+		val = append(val, v)
+	}
+	return val, nil
+}
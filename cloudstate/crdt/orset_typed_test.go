@@ -0,0 +1,76 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+)
+
+func TestTypedORSet(t *testing.T) {
+	t.Run("should add and remove typed elements", func(t *testing.T) {
+		s := ORSetOf(NewORSet(), encoding.StringCodec)
+		for _, v := range []string{"one", "two", "three"} {
+			if err := s.Add(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.Remove("two"); err != nil {
+			t.Fatal(err)
+		}
+		if s.Size() != 2 {
+			t.Fatalf("s.Size(): %v; want: %v", s.Size(), 2)
+		}
+		if has, _ := s.Contains("two"); has {
+			t.Fatal("set should not contain two")
+		}
+		value, err := s.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(value)
+		if len(value) != 2 || value[0] != "one" || value[1] != "three" {
+			t.Fatalf("s.Value(): %v; want: %v", value, []string{"one", "three"})
+		}
+	})
+
+	t.Run("should produce the same delta as the underlying set", func(t *testing.T) {
+		s := ORSetOf(NewORSet(), encoding.StringCodec)
+		if err := s.Add("one"); err != nil {
+			t.Fatal(err)
+		}
+		delta := encDecDelta(s.ORSet().Delta())
+		if !contains(delta.GetOrset().GetAdded(), "one") {
+			t.Fatal("delta did not contain one")
+		}
+	})
+
+	t.Run("should return an element rejected by the limits", func(t *testing.T) {
+		s := NewORSet()
+		s.setSizeGuard(&sizeGuard{limits: Limits{MaxElements: 1}})
+		typed := ORSetOf(s, encoding.StringCodec)
+		if err := typed.Add("one"); err != nil {
+			t.Fatal(err)
+		}
+		var limitErr *LimitError
+		if err := typed.Add("two"); !errors.As(err, &limitErr) {
+			t.Fatalf("typed.Add(): %v; want: a limit error", err)
+		}
+	})
+}
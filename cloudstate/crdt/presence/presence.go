@@ -0,0 +1,224 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package presence implements online presence tracking backed by CRDTs.
+//
+// The state of a user is an ORMap with a Vote of all nodes, where a node
+// votes for a user being online as long as it has a connection of the
+// user, an ORMap with a Vote per device, where a node votes for a device
+// being connected as long as it has a connection of the device, and an
+// ORMap of the devices metadata held in an LWWRegister.
+package presence
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/cloudstateio/go-support/cloudstate/crdt"
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+var (
+	onlineKey   = encoding.String("online")
+	devicesKey  = encoding.String("devices")
+	metadataKey = encoding.String("metadata")
+)
+
+// A Device is a connected device of a user.
+type Device struct {
+	ID       string
+	Metadata *any.Any
+}
+
+// Presence tracks the presence of a user.
+type Presence struct {
+	state    *crdt.ORMap
+	online   *crdt.Vote
+	devices  *crdt.ORMap
+	metadata *crdt.ORMap
+	// connections counts the connections per device of this node.
+	connections map[string]int
+}
+
+// New returns a Presence with its state held by an ORMap.
+func New(state *crdt.ORMap) (*Presence, error) {
+	online, err := state.GetOrCreateVote(onlineKey)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := state.GetOrCreateORMap(devicesKey)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := state.GetOrCreateORMap(metadataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Presence{
+		state:       state,
+		online:      online,
+		devices:     devices,
+		metadata:    metadata,
+		connections: make(map[string]int),
+	}, nil
+}
+
+// State returns the ORMap holding the presence state.
+func (p *Presence) State() *crdt.ORMap {
+	return p.state
+}
+
+// Online returns true if the user is connected on any node.
+func (p *Presence) Online() bool {
+	return p.online.AtLeastOne()
+}
+
+// Devices returns the connected devices of the user sorted by their ID.
+func (p *Presence) Devices() ([]Device, error) {
+	devices := make([]Device, 0, p.devices.Size())
+	for _, e := range p.devices.Entries() {
+		v, ok := e.Value.(*crdt.Vote)
+		if !ok {
+			return nil, &crdt.TypeMismatchError{Key: e.Key, Expected: "Vote", Actual: e.Value}
+		}
+		if !v.AtLeastOne() {
+			continue
+		}
+		d := Device{ID: encoding.DecodeString(e.Key)}
+		r, err := p.metadata.LWWRegister(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil {
+			d.Metadata = r.Value()
+		}
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].ID < devices[j].ID
+	})
+	return devices, nil
+}
+
+// Connect connects a device of the user for as long as the streamed command
+// of ctx is not cancelled. Metadata, if not nil, is set for the device.
+func (p *Presence) Connect(ctx *crdt.CommandContext, device string, metadata *any.Any) error {
+	if !ctx.Streamed() {
+		return errors.New("connect has to be a streamed command")
+	}
+	key := encoding.String(device)
+	v, err := p.devices.GetOrCreateVote(key)
+	if err != nil {
+		return err
+	}
+	if metadata != nil {
+		r, err := p.metadata.GetOrCreateLWWRegister(key, metadata)
+		if err != nil {
+			return err
+		}
+		if !proto.Equal(r.Value(), metadata) {
+			r.Set(metadata)
+		}
+	}
+	p.connections[device]++
+	if p.connections[device] == 1 {
+		v.Vote(true)
+		if len(p.connections) == 1 {
+			p.online.Vote(true)
+		}
+	}
+	ctx.CancelFunc(func(c *crdt.CommandContext) error {
+		p.disconnect(device)
+		return nil
+	})
+	return nil
+}
+
+func (p *Presence) disconnect(device string) {
+	p.connections[device]--
+	if p.connections[device] > 0 {
+		return
+	}
+	delete(p.connections, device)
+	key := encoding.String(device)
+	if v, err := p.devices.Vote(key); err == nil && v != nil {
+		v.Vote(false)
+		// Only a device no node is connected to anymore is deleted.
+		if !v.AtLeastOne() {
+			p.devices.Delete(key)
+			p.metadata.Delete(key)
+		}
+	}
+	if len(p.connections) == 0 {
+		p.online.Vote(false)
+	}
+}
+
+// A StatusFunc returns the reply for an online status.
+type StatusFunc func(online bool) (*any.Any, error)
+
+// Monitor returns the reply for the current online status and, for a
+// streamed command, streams a reply for every transition between online
+// and offline.
+func (p *Presence) Monitor(ctx *crdt.CommandContext, status StatusFunc) (*any.Any, error) {
+	online := p.Online()
+	ctx.ChangeFunc(func(c *crdt.CommandContext) (*any.Any, error) {
+		if p.Online() == online {
+			return nil, nil
+		}
+		online = p.Online()
+		return status(online)
+	})
+	return status(online)
+}
+
+// CommandHandler handles a command of a presence entity.
+type CommandHandler func(ctx *crdt.CommandContext, p *Presence, name string, msg proto.Message) (*any.Any, error)
+
+// Entity returns a CRDT entity for the service that tracks the presence of
+// a user per entity.
+func Entity(serviceName crdt.ServiceName, handler CommandHandler) *crdt.Entity {
+	return &crdt.Entity{
+		ServiceName: serviceName,
+		EntityFunc: func(id crdt.EntityID) crdt.EntityHandler {
+			return &presenceEntity{handler: handler}
+		},
+	}
+}
+
+type presenceEntity struct {
+	handler  CommandHandler
+	presence *Presence
+}
+
+func (e *presenceEntity) HandleCommand(ctx *crdt.CommandContext, name string, msg proto.Message) (*any.Any, error) {
+	return e.handler(ctx, e.presence, name, msg)
+}
+
+func (e *presenceEntity) Default(ctx *crdt.Context) (crdt.CRDT, error) {
+	return crdt.NewORMap(), nil
+}
+
+func (e *presenceEntity) Set(ctx *crdt.Context, state crdt.CRDT) error {
+	m, ok := state.(*crdt.ORMap)
+	if !ok {
+		return errors.New("unable to set state, it is not an ORMap")
+	}
+	var err error
+	e.presence, err = New(m)
+	return err
+}
@@ -0,0 +1,164 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package presence
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/crdt"
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+)
+
+type stream struct {
+	grpc.ServerStream
+	in  []*entity.CrdtStreamIn
+	out []*entity.CrdtStreamOut
+}
+
+func (s *stream) Send(out *entity.CrdtStreamOut) error {
+	s.out = append(s.out, out)
+	return nil
+}
+
+func (s *stream) Recv() (*entity.CrdtStreamIn, error) {
+	if len(s.in) == 0 {
+		return nil, io.EOF
+	}
+	in := s.in[0]
+	s.in = s.in[1:]
+	return in, nil
+}
+
+func (s *stream) Context() context.Context {
+	return context.Background()
+}
+
+func command(t *testing.T, id int64, name string, device string) *entity.CrdtStreamIn {
+	t.Helper()
+	payload, err := encoding.MarshalAny(&wrappers.StringValue{Value: device})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &entity.CrdtStreamIn{Message: &entity.CrdtStreamIn_Command{Command: &protocol.Command{
+		EntityId: "alice",
+		Id:       id,
+		Name:     name,
+		Payload:  payload,
+		Streamed: true,
+	}}}
+}
+
+func TestPresence(t *testing.T) {
+	var devices []Device
+	e := Entity("test.Presence", func(ctx *crdt.CommandContext, p *Presence, name string, msg proto.Message) (*any.Any, error) {
+		switch name {
+		case "Connect":
+			if err := p.Connect(ctx, msg.(*wrappers.StringValue).Value, encoding.String("metadata")); err != nil {
+				return nil, err
+			}
+			var err error
+			devices, err = p.Devices()
+			return encoding.Empty, err
+		case "Monitor":
+			return p.Monitor(ctx, func(online bool) (*any.Any, error) {
+				return encoding.Bool(online), nil
+			})
+		}
+		return nil, nil
+	})
+	server := crdt.NewServer()
+	if err := server.Register(e); err != nil {
+		t.Fatal(err)
+	}
+	s := &stream{in: []*entity.CrdtStreamIn{
+		{Message: &entity.CrdtStreamIn_Init{Init: &entity.CrdtInit{ServiceName: "test.Presence", EntityId: "alice"}}},
+		command(t, 1, "Monitor", ""),
+		command(t, 2, "Connect", "phone"),
+		command(t, 3, "Connect", "laptop"),
+		{Message: &entity.CrdtStreamIn_StreamCancelled{StreamCancelled: &protocol.StreamCancelled{EntityId: "alice", Id: 2}}},
+		{Message: &entity.CrdtStreamIn_StreamCancelled{StreamCancelled: &protocol.StreamCancelled{EntityId: "alice", Id: 3}}},
+	}}
+	if err := server.Handle(s); err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 2 || devices[0].ID != "laptop" || devices[1].ID != "phone" {
+		t.Fatalf("devices: %+v; want: laptop and phone", devices)
+	}
+	statuses := make([]bool, 0)
+	for _, out := range s.out {
+		if reply := out.GetReply(); reply.GetCommandId() == 1 {
+			statuses = append(statuses, encoding.DecodeBool(reply.GetClientAction().GetReply().GetPayload()))
+		}
+		if m := out.GetStreamedMessage(); m.GetCommandId() == 1 {
+			statuses = append(statuses, encoding.DecodeBool(m.GetClientAction().GetReply().GetPayload()))
+		}
+	}
+	if len(statuses) != 3 || statuses[0] || !statuses[1] || statuses[2] {
+		t.Fatalf("statuses: %v; want: %v", statuses, []bool{false, true, false})
+	}
+}
+
+func TestPresenceDisconnectKeepsRemoteDevices(t *testing.T) {
+	var devices []Device
+	e := Entity("test.Presence", func(ctx *crdt.CommandContext, p *Presence, name string, msg proto.Message) (*any.Any, error) {
+		switch name {
+		case "Connect":
+			return encoding.Empty, p.Connect(ctx, msg.(*wrappers.StringValue).Value, nil)
+		case "Devices":
+			var err error
+			devices, err = p.Devices()
+			return encoding.Empty, err
+		}
+		return nil, nil
+	})
+	server := crdt.NewServer()
+	if err := server.Register(e); err != nil {
+		t.Fatal(err)
+	}
+	// phone is connected on this and on another node.
+	remote := &entity.CrdtDelta{Delta: &entity.CrdtDelta_Ormap{Ormap: &entity.ORMapDelta{
+		Updated: []*entity.ORMapEntryDelta{{
+			Key: devicesKey,
+			Delta: &entity.CrdtDelta{Delta: &entity.CrdtDelta_Ormap{Ormap: &entity.ORMapDelta{
+				Updated: []*entity.ORMapEntryDelta{{
+					Key:   encoding.String("phone"),
+					Delta: &entity.CrdtDelta{Delta: &entity.CrdtDelta_Vote{Vote: &entity.VoteDelta{SelfVote: true, VotesFor: 2, TotalVoters: 2}}},
+				}},
+			}}},
+		}},
+	}}}
+	s := &stream{in: []*entity.CrdtStreamIn{
+		{Message: &entity.CrdtStreamIn_Init{Init: &entity.CrdtInit{ServiceName: "test.Presence", EntityId: "alice"}}},
+		command(t, 1, "Connect", "phone"),
+		{Message: &entity.CrdtStreamIn_Delta{Delta: remote}},
+		{Message: &entity.CrdtStreamIn_StreamCancelled{StreamCancelled: &protocol.StreamCancelled{EntityId: "alice", Id: 1}}},
+		command(t, 2, "Devices", ""),
+	}}
+	if err := server.Handle(s); err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 || devices[0].ID != "phone" {
+		t.Fatalf("devices: %+v; want: phone", devices)
+	}
+}
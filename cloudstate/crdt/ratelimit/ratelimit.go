@@ -0,0 +1,214 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit implements a rate limiter backed by CRDTs.
+//
+// The quota consumed per time window is stored in a PNCounter of an ORMap
+// keyed by the window. Replicas consume quota concurrently and their
+// consumption converges, so a limit may be exceeded by what replicas
+// consumed before seeing each others updates.
+package ratelimit
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudstateio/go-support/cloudstate/crdt"
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// Config configures a Limiter.
+type Config struct {
+	// Limit is the quota per window.
+	Limit int64
+	// Window is the duration of a window.
+	Window time.Duration
+	// Sliding weights the consumption of the previous window by how much
+	// it overlaps a window ending now. Otherwise windows are fixed.
+	Sliding bool
+	// Now returns the current time, time.Now if not set.
+	Now func() time.Time
+}
+
+func (c Config) validate() error {
+	if c.Limit <= 0 {
+		return errors.New("the limit has to be positive")
+	}
+	if c.Window <= 0 {
+		return errors.New("the window has to be positive")
+	}
+	return nil
+}
+
+func (c Config) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// A Limiter limits the quota consumed per window.
+type Limiter struct {
+	state  *crdt.ORMap
+	config Config
+}
+
+// New returns a Limiter with its state held by an ORMap.
+func New(state *crdt.ORMap, config Config) (*Limiter, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &Limiter{state: state, config: config}, nil
+}
+
+// State returns the ORMap holding the limiters state.
+func (l *Limiter) State() *crdt.ORMap {
+	return l.state
+}
+
+// Consume consumes n units of quota if they are available and returns the
+// remaining quota. Expired windows are pruned. n has to be positive.
+func (l *Limiter) Consume(n int64) (remaining int64, allowed bool, err error) {
+	if n <= 0 {
+		return 0, false, errors.New("the quota consumed has to be positive")
+	}
+	now := l.config.now()
+	l.prune(now)
+	used, err := l.used(now)
+	if err != nil {
+		return 0, false, err
+	}
+	if used+n > l.config.Limit {
+		return atLeastZero(l.config.Limit - used), false, nil
+	}
+	c, err := l.state.GetOrCreatePNCounter(l.key(l.window(now)))
+	if err != nil {
+		return 0, false, err
+	}
+	c.Increment(n)
+	return l.config.Limit - used - n, true, nil
+}
+
+// Remaining returns the remaining quota.
+func (l *Limiter) Remaining() (int64, error) {
+	used, err := l.used(l.config.now())
+	if err != nil {
+		return 0, err
+	}
+	return atLeastZero(l.config.Limit - used), nil
+}
+
+// Prune deletes the counters of expired windows.
+func (l *Limiter) Prune() {
+	l.prune(l.config.now())
+}
+
+func (l *Limiter) prune(now time.Time) {
+	oldest := l.window(now)
+	if l.config.Sliding {
+		oldest--
+	}
+	for _, k := range l.state.Keys() {
+		if encoding.DecodeInt64(k) < oldest {
+			l.state.Delete(k)
+		}
+	}
+}
+
+// used returns the quota used for a window ending now.
+func (l *Limiter) used(now time.Time) (int64, error) {
+	w := l.window(now)
+	current, err := l.consumed(w)
+	if err != nil || !l.config.Sliding {
+		return current, err
+	}
+	previous, err := l.consumed(w - 1)
+	if err != nil {
+		return 0, err
+	}
+	elapsed := now.UnixNano() - w*l.config.Window.Nanoseconds()
+	overlap := 1 - float64(elapsed)/float64(l.config.Window.Nanoseconds())
+	return current + int64(float64(previous)*overlap), nil
+}
+
+func (l *Limiter) consumed(window int64) (int64, error) {
+	c, err := l.state.PNCounter(l.key(window))
+	if err != nil || c == nil {
+		return 0, err
+	}
+	return c.Value(), nil
+}
+
+// window returns the index of the fixed window at t.
+func (l *Limiter) window(t time.Time) int64 {
+	return t.UnixNano() / l.config.Window.Nanoseconds()
+}
+
+func (l *Limiter) key(window int64) *any.Any {
+	return encoding.Int64(window)
+}
+
+func atLeastZero(r int64) int64 {
+	if r < 0 {
+		return 0
+	}
+	return r
+}
+
+// CommandHandler handles a command of a rate limiter entity.
+type CommandHandler func(ctx *crdt.CommandContext, l *Limiter, name string, msg proto.Message) (*any.Any, error)
+
+// Entity returns a CRDT entity for the service that holds a Limiter per
+// entity, for example one per tenant. Commands are handled by handler
+// after expired windows have been pruned. An invalid config returns an
+// error.
+func Entity(serviceName crdt.ServiceName, config Config, handler CommandHandler) (*crdt.Entity, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &crdt.Entity{
+		ServiceName: serviceName,
+		EntityFunc: func(id crdt.EntityID) crdt.EntityHandler {
+			return &entity{config: config, handler: handler}
+		},
+	}, nil
+}
+
+type entity struct {
+	config  Config
+	handler CommandHandler
+	limiter *Limiter
+}
+
+func (e *entity) HandleCommand(ctx *crdt.CommandContext, name string, msg proto.Message) (*any.Any, error) {
+	e.limiter.Prune()
+	return e.handler(ctx, e.limiter, name, msg)
+}
+
+func (e *entity) Default(ctx *crdt.Context) (crdt.CRDT, error) {
+	return crdt.NewORMap(), nil
+}
+
+func (e *entity) Set(ctx *crdt.Context, state crdt.CRDT) error {
+	m, ok := state.(*crdt.ORMap)
+	if !ok {
+		return errors.New("unable to set state, it is not an ORMap")
+	}
+	var err error
+	e.limiter, err = New(m, e.config)
+	return err
+}
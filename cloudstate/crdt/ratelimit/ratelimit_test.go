@@ -0,0 +1,143 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudstateio/go-support/cloudstate/crdt"
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func consume(t *testing.T, l *Limiter, n int64, wantRemaining int64, wantAllowed bool) {
+	t.Helper()
+	remaining, allowed, err := l.Consume(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining != wantRemaining || allowed != wantAllowed {
+		t.Fatalf("Consume(%v): %v, %v; want: %v, %v", n, remaining, allowed, wantRemaining, wantAllowed)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	t.Run("a fixed window limits and resets", func(t *testing.T) {
+		c := &clock{now: time.Unix(100, 0)}
+		l, err := New(crdt.NewORMap(), Config{Limit: 3, Window: time.Minute, Now: c.Now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		consume(t, l, 2, 1, true)
+		consume(t, l, 2, 1, false)
+		consume(t, l, 1, 0, true)
+		c.now = c.now.Add(time.Minute)
+		if r, _ := l.Remaining(); r != 3 {
+			t.Fatalf("Remaining(): %v; want: %v", r, 3)
+		}
+		consume(t, l, 1, 2, true)
+		if s := l.State().Size(); s != 1 {
+			t.Fatalf("State().Size(): %v; want: %v", s, 1)
+		}
+	})
+
+	t.Run("a sliding window weights the previous window", func(t *testing.T) {
+		c := &clock{now: time.Unix(0, 0)}
+		l, err := New(crdt.NewORMap(), Config{Limit: 10, Window: 10 * time.Second, Sliding: true, Now: c.Now})
+		if err != nil {
+			t.Fatal(err)
+		}
+		consume(t, l, 10, 0, true)
+		c.now = c.now.Add(15 * time.Second)
+		if r, _ := l.Remaining(); r != 5 {
+			t.Fatalf("Remaining(): %v; want: %v", r, 5)
+		}
+		consume(t, l, 6, 5, false)
+		consume(t, l, 5, 0, true)
+		c.now = c.now.Add(10 * time.Second)
+		l.Prune()
+		if s := l.State().Size(); s != 1 {
+			t.Fatalf("State().Size(): %v; want: %v", s, 1)
+		}
+	})
+
+	t.Run("an invalid config fails", func(t *testing.T) {
+		if _, err := New(crdt.NewORMap(), Config{Limit: 1}); err == nil {
+			t.Fatal("New() should have failed")
+		}
+		if _, err := Entity("test.Limiter", Config{Window: time.Second}, nil); err == nil {
+			t.Fatal("Entity() should have failed")
+		}
+	})
+
+	t.Run("a non-positive quota fails", func(t *testing.T) {
+		l, err := New(crdt.NewORMap(), Config{Limit: 3, Window: time.Minute})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []int64{0, -1} {
+			if _, _, err := l.Consume(n); err == nil {
+				t.Fatalf("Consume(%v) should have failed", n)
+			}
+		}
+	})
+}
+
+func TestEntity(t *testing.T) {
+	c := &clock{now: time.Unix(0, 0)}
+	e, err := Entity("test.Limiter", Config{Limit: 2, Window: time.Second, Now: c.Now},
+		func(ctx *crdt.CommandContext, l *Limiter, name string, msg proto.Message) (*any.Any, error) {
+			remaining, _, err := l.Consume(1)
+			if err != nil {
+				return nil, err
+			}
+			return encoding.Int64(remaining), nil
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := e.EntityFunc("tenant")
+	state, err := h.Default(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Set(nil, state); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := h.HandleCommand(nil, "Consume", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := encoding.DecodeInt64(reply); r != 1 {
+		t.Fatalf("remaining: %v; want: %v", r, 1)
+	}
+	c.now = c.now.Add(time.Second)
+	_, _ = h.HandleCommand(nil, "Consume", nil)
+	if s := state.(*crdt.ORMap).Size(); s != 1 {
+		t.Fatalf("state.Size(): %v; want: %v", s, 1)
+	}
+}
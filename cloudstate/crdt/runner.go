@@ -18,15 +18,20 @@ package crdt
 import (
 	"errors"
 	"fmt"
+	"log"
+	"time"
 
 	"github.com/cloudstateio/go-support/cloudstate/entity"
 	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/ptypes/any"
 )
 
 // runner runs a stream with the help of a context.
 type runner struct {
 	stream  entity.Crdt_HandleServer
 	context *Context
+	// clock returns the current time, time.Now if not set.
+	clock func() time.Time
 }
 
 // handleDelta handles an incoming delta message to be applied to the current state.
@@ -38,6 +43,7 @@ func (r *runner) handleDelta(delta *entity.CrdtDelta) error {
 		if err != nil {
 			return err
 		}
+		r.context.adopt(s)
 		r.context.crdt = s
 	}
 	return r.context.crdt.applyDelta(delta)
@@ -60,11 +66,15 @@ func (r *runner) handleCancellation(cancelled *protocol.StreamCancelled) error {
 		})
 	}
 	// Notify the user about the cancellation.
-	if err := ctx.cancelled(); err != nil {
+	r.context.current = ctx
+	err := ctx.cancelled()
+	r.context.current = nil
+	r.logRejected()
+	if err != nil {
 		return err
 	}
 	stateAction := ctx.stateAction()
-	err := r.sendCancelledMessage(&entity.CrdtStreamCancelledResponse{
+	err = r.sendCancelledMessage(&entity.CrdtStreamCancelledResponse{
 		CommandId:   id.Value(),
 		StateAction: stateAction,
 		SideEffects: ctx.sideEffects,
@@ -96,7 +106,27 @@ func (r *runner) handleCommand(cmd *protocol.Command) (streamError error) {
 		return fmt.Errorf("the command entity id: %s does not match the initialized entity id: %s", cmd.EntityId, r.context.EntityID)
 	}
 	ctx := r.context.commandContextFor(cmd)
+	r.logRejected()
+	r.context.current = ctx
 	reply, err := ctx.runCommand(cmd)
+	r.context.current = nil
+	if limitErr := r.context.guard.err(); limitErr != nil {
+		// changes rejected by a limit fail the command, also if the
+		// rejection has been returned by the command handler.
+		var returned *LimitError
+		if err == nil || errors.As(err, &returned) {
+			err = protocol.ClientError{Err: limitErr}
+		}
+		// the changes of a rejected command are not replicated, so that
+		// no later command carries them.
+		if r.context.crdt != nil {
+			r.context.crdt.resetDelta()
+		}
+	}
+	if err == nil && ctx.failed == nil {
+		err = r.context.targets.Check(ctx.forward, ctx.sideEffects)
+	}
+	defer r.context.reportUsage()
 	if err != nil && !errors.Is(err, protocol.ClientError{}) {
 		return err
 	}
@@ -152,54 +182,124 @@ func (r *runner) handleCommand(cmd *protocol.Command) (streamError error) {
 	return nil
 }
 
+// handleChange records a change of the CRDT for all streamed commands and
+// notifies those due.
 func (r *runner) handleChange() error {
+	now := r.now()
 	for _, ctx := range r.context.streamedCtx {
 		if ctx.change == nil {
 			continue
 		}
-		reply, err := ctx.changed()
-
-		// TODO: we have to clarify error path from here on.
-		if errors.Is(err, ErrCtxFailCalled) {
-			// ctx.clientActionFor will report a failure for that.
-			reply = nil
-		} else if err != nil {
-			return err
+		ctx.changes.changed(now)
+		if !ctx.changes.dueAt(now) {
+			continue
 		}
-		clientAction, err := ctx.clientActionFor(reply)
-		if err != nil {
+		if err := r.notifyChange(ctx, now); err != nil {
 			return err
 		}
-		if ctx.failed != nil {
-			delete(ctx.streamedCtx, ctx.CommandID)
-			if err := r.sendStreamedMessage(&entity.CrdtStreamedMessage{
-				CommandId:    ctx.CommandID.Value(),
-				ClientAction: clientAction,
-			}); err != nil {
-				return err
-			}
+	}
+	return nil
+}
+
+// flushChanges notifies streamed commands with pending changes that are due.
+func (r *runner) flushChanges() error {
+	now := r.now()
+	for _, ctx := range r.context.streamedCtx {
+		if ctx.change == nil || !ctx.changes.dueAt(now) {
 			continue
 		}
-		if clientAction != nil || ctx.ended || len(ctx.sideEffects) > 0 {
-			if ctx.ended {
-				delete(ctx.streamedCtx, ctx.CommandID)
-			}
-			msg := &entity.CrdtStreamedMessage{
-				CommandId:    ctx.CommandID.Value(),
-				ClientAction: clientAction,
-				SideEffects:  ctx.sideEffects,
-				EndStream:    ctx.ended,
-			}
-			if err := r.sendStreamedMessage(msg); err != nil {
-				return err
-			}
-			ctx.clearSideEffect()
+		if err := r.notifyChange(ctx, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextChange returns the earliest time a pending change notification is due.
+func (r *runner) nextChange() (time.Time, bool) {
+	var next time.Time
+	var ok bool
+	for _, ctx := range r.context.streamedCtx {
+		if ctx.change == nil {
 			continue
 		}
+		if due, has := ctx.changes.due(); has && (!ok || due.Before(next)) {
+			next, ok = due, true
+		}
+	}
+	return next, ok
+}
+
+func (r *runner) notifyChange(ctx *CommandContext, now time.Time) error {
+	reply, err := ctx.changed()
+	if err == nil && ctx.failed == nil && !ctx.ended && len(ctx.sideEffects) == 0 && ctx.changes.unchanged(reply) {
+		ctx.changes.dropped()
+		return nil
+	}
+	ctx.changes.sent(now, reply)
+	return r.sendStreamedReply(ctx, reply, err)
+}
+
+// sendStreamedReply sends a reply of a streamed command returned by one of
+// its handlers.
+func (r *runner) sendStreamedReply(ctx *CommandContext, reply *any.Any, err error) error {
+	if err == nil && ctx.failed == nil {
+		if err = r.context.targets.Check(ctx.forward, ctx.sideEffects); errors.Is(err, protocol.ClientError{}) {
+			ctx.fail(err)
+			err = ErrCtxFailCalled
+		}
+	}
+	// TODO: we have to clarify error path from here on.
+	if errors.Is(err, ErrCtxFailCalled) {
+		// ctx.clientActionFor will report a failure for that.
+		reply = nil
+	} else if err != nil {
+		return err
+	}
+	clientAction, err := ctx.clientActionFor(reply)
+	if err != nil {
+		return err
+	}
+	if ctx.failed != nil {
+		delete(ctx.streamedCtx, ctx.CommandID)
+		return r.sendStreamedMessage(&entity.CrdtStreamedMessage{
+			CommandId:    ctx.CommandID.Value(),
+			ClientAction: clientAction,
+		})
+	}
+	if clientAction != nil || ctx.ended || len(ctx.sideEffects) > 0 {
+		if ctx.ended {
+			delete(ctx.streamedCtx, ctx.CommandID)
+		}
+		msg := &entity.CrdtStreamedMessage{
+			CommandId:    ctx.CommandID.Value(),
+			ClientAction: clientAction,
+			SideEffects:  ctx.sideEffects,
+			EndStream:    ctx.ended,
+		}
+		if err := r.sendStreamedMessage(msg); err != nil {
+			return err
+		}
+		ctx.clearSideEffect()
 	}
 	return nil
 }
 
+// logRejected logs changes rejected by the limits of the entity outside of
+// a command, as there is no command to fail.
+func (r *runner) logRejected() {
+	if err := r.context.guard.err(); err != nil {
+		log.Printf("a change of entity %q has been rejected outside of a command: %v", r.context.EntityID, err)
+	}
+}
+
+func (r *runner) now() time.Time {
+	if r.clock != nil {
+		return r.clock()
+	}
+	return time.Now()
+}
+
 func (r *runner) sendStreamedMessage(msg *entity.CrdtStreamedMessage) error {
 	return r.stream.Send(&entity.CrdtStreamOut{
 		Message: &entity.CrdtStreamOut_StreamedMessage{
@@ -21,8 +21,10 @@ import (
 	"io"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/cloudstateio/go-support/cloudstate/entity"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -34,6 +36,11 @@ type Server struct {
 	// entities has descriptions of entities registered by service names
 	entities map[ServiceName]*Entity
 
+	// interceptors intercept commands of all entities.
+	interceptors []protocol.Interceptor
+	// targets validates forward and side effect targets, if set.
+	targets *protocol.TargetValidation
+
 	entity.UnimplementedCrdtServer
 }
 
@@ -61,6 +68,34 @@ func (s *Server) Register(e *Entity) error {
 	return nil
 }
 
+// Intercept adds interceptors for commands of all entities of the server.
+// They run before the interceptors of an entity.
+func (s *Server) Intercept(interceptors ...protocol.Interceptor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+func (s *Server) globalInterceptors() []protocol.Interceptor {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.interceptors
+}
+
+// ValidateTargets sets the validation of forward and side effect targets
+// for all entities of the server.
+func (s *Server) ValidateTargets(v *protocol.TargetValidation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets = v
+}
+
+func (s *Server) targetValidation() *protocol.TargetValidation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.targets
+}
+
 // After invoking handle, the first message sent will always be a CrdtInit message,
 // containing the entity ID, and, if it exists or is available, the current value of
 // the entity. After that, one or more commands may be sent, as well as deltas as
@@ -76,8 +111,26 @@ func (s *Server) Handle(stream entity.Crdt_HandleServer) error {
 			panic(r)
 		}
 	}()
+	// Messages are received on their own goroutine, so that the stream
+	// can be handled while waiting for the next message.
+	done := make(chan struct{})
+	defer close(done)
+	in := make(chan received)
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			select {
+			case in <- received{msg: msg, err: err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
 	for {
-		err := s.handle(stream)
+		err := s.handle(stream, in)
 		if err == nil {
 			continue
 		}
@@ -95,26 +148,40 @@ func (s *Server) Handle(stream entity.Crdt_HandleServer) error {
 	}
 }
 
+// received is a message or error received from a stream.
+type received struct {
+	msg *entity.CrdtStreamIn
+	err error
+}
+
 // handle handles a streams messages to be received.
 // io.EOF returned will close the stream gracefully, other errors will be sent
 // to the proxy as a failure and a nil error value restarts the stream to be
 // reused.
-func (s *Server) handle(stream entity.Crdt_HandleServer) error {
-	first, err := stream.Recv()
-	if err != nil {
-		return err
+func (s *Server) handle(stream entity.Crdt_HandleServer, in <-chan received) error {
+	first := <-in
+	if first.err != nil {
+		return first.err
 	}
 	r := &runner{stream: stream}
-	switch m := first.GetMessage().(type) {
+	switch m := first.msg.GetMessage().(type) {
 	case *entity.CrdtStreamIn_Init:
 		// First, always a CrdtInit message must be received.
-		if err = s.handleInit(m.Init, r); err != nil {
+		if err := s.handleInit(m.Init, r); err != nil {
 			return fmt.Errorf("handling of CrdtInit failed with: %w", err)
 		}
 	default:
 		return fmt.Errorf("a message was received without having a CrdtInit message first: %v", m)
 	}
 	// Handle all other messages after a CrdtInit message has been received.
+	// Pending change notifications and timers of streamed commands are
+	// handled by a timer when they are due.
+	timer := time.NewTimer(0)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+	var timerC <-chan time.Time
 	for {
 		if r.context.deleted {
 			// With a context flagged deleted, a CrdtDelete
@@ -126,9 +193,30 @@ func (s *Server) handle(stream entity.Crdt_HandleServer) error {
 			// failed means deactivated. We may never get this far.
 			return nil
 		}
-		msg, err := r.stream.Recv()
-		if err != nil {
-			return err
+		if next, ok := r.nextDeadline(); ok {
+			timer.Reset(time.Until(next))
+			timerC = timer.C
+		}
+		var msg *entity.CrdtStreamIn
+		select {
+		case <-timerC:
+			timerC = nil
+			if err := r.flushChanges(); err != nil {
+				return err
+			}
+			if err := r.fireTimers(); err != nil {
+				return err
+			}
+			continue
+		case rec := <-in:
+			if timerC != nil && !timer.Stop() {
+				<-timer.C
+			}
+			timerC = nil
+			if rec.err != nil {
+				return rec.err
+			}
+			msg = rec.msg
 		}
 		switch m := msg.GetMessage().(type) {
 		case *entity.CrdtStreamIn_Delta:
@@ -187,8 +275,14 @@ func (s *Server) handleInit(init *entity.CrdtInit, r *runner) error {
 		Instance:    entity.EntityFunc(id),
 		created:     false,
 		ctx:         r.stream.Context(), // This context is stable as long as the runner runs.
+		clock:       r.now,
 		streamedCtx: make(map[CommandID]*CommandContext),
 	}
+	r.context.interceptors = protocol.Interceptors(s.globalInterceptors(), entity.Interceptors)
+	r.context.targets = s.targetValidation()
+	if entity.Limits.enabled() {
+		r.context.guard = &sizeGuard{limits: entity.Limits}
+	}
 	// The init message may have an initial delta.
 	if delta := init.GetDelta(); delta != nil {
 		if err := r.handleDelta(delta); err != nil {
@@ -196,5 +290,9 @@ func (s *Server) handleInit(init *entity.CrdtInit, r *runner) error {
 		}
 	}
 	// The user entity can provide a CRDT through a default function if none is set.
-	return r.context.initDefault()
+	if err := r.context.initDefault(); err != nil {
+		return err
+	}
+	r.logRejected()
+	return nil
 }
@@ -0,0 +1,143 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// A TimerFunc is called when a timer of a streamed command fires. Like a
+// ChangeFunc, it returns the reply to be streamed, if any, and may end the
+// stream. It is not allowed to change the CRDT.
+type TimerFunc func(c *CommandContext) (*any.Any, error)
+
+// A Timer calls a TimerFunc once or periodically on the entities goroutine.
+// A timer is stopped when the stream of its command ends.
+type Timer struct {
+	due     time.Time
+	period  time.Duration
+	f       TimerFunc
+	stopped bool
+}
+
+// Stop stops the timer. It has to be called from a handler of the entity.
+func (t *Timer) Stop() {
+	t.stopped = true
+}
+
+// AfterFunc calls f once after the duration d. For non-streamed contexts
+// this is a `no operation` and returns a stopped timer.
+func (c *CommandContext) AfterFunc(d time.Duration, f TimerFunc) *Timer {
+	return c.addTimer(d, 0, f)
+}
+
+// TickerFunc calls f every period d. For non-streamed contexts this is a
+// `no operation` and returns a stopped timer.
+func (c *CommandContext) TickerFunc(d time.Duration, f TimerFunc) *Timer {
+	if d <= 0 {
+		return &Timer{stopped: true}
+	}
+	return c.addTimer(d, d, f)
+}
+
+func (c *CommandContext) addTimer(d time.Duration, period time.Duration, f TimerFunc) *Timer {
+	t := &Timer{due: c.now().Add(d), period: period, f: f}
+	if !c.Streamed() {
+		t.stopped = true
+		return t
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// nextTimer returns the earliest time a timer of the context is due.
+func (c *CommandContext) nextTimer() (time.Time, bool) {
+	var next time.Time
+	var ok bool
+	for _, t := range c.timers {
+		if !t.stopped && (!ok || t.due.Before(next)) {
+			next, ok = t.due, true
+		}
+	}
+	return next, ok
+}
+
+// fire calls the timer function, it is not allowed to change the CRDT.
+func (t *Timer) fire(c *CommandContext) (reply *any.Any, err error) {
+	reply, err = t.f(c)
+	if c.crdt != nil && c.crdt.HasDelta() {
+		err = ErrStateChanged
+	}
+	return
+}
+
+// fireTimers calls the timers that are due.
+func (r *runner) fireTimers() error {
+	now := r.now()
+	for _, ctx := range r.context.streamedCtx {
+		if err := r.fireTimersOf(ctx, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fireTimersOf calls the timers of a context that are due. Timers added by
+// a timer function are due earliest on the next call. If a timer ends the
+// stream of the command, its remaining timers are dropped.
+func (r *runner) fireTimersOf(ctx *CommandContext, now time.Time) error {
+	n := len(ctx.timers)
+	timers := make([]*Timer, 0, n)
+	for _, t := range ctx.timers[:n] {
+		if t.stopped {
+			continue
+		}
+		if t.due.After(now) {
+			timers = append(timers, t)
+			continue
+		}
+		reply, err := t.fire(ctx)
+		if err := r.sendStreamedReply(ctx, reply, err); err != nil {
+			return err
+		}
+		if r.context.streamedCtx[ctx.CommandID] != ctx {
+			return nil
+		}
+		if t.period > 0 && !t.stopped {
+			t.due = t.due.Add(t.period)
+			if !t.due.After(now) {
+				t.due = now.Add(t.period)
+			}
+			timers = append(timers, t)
+		}
+	}
+	ctx.timers = append(timers, ctx.timers[n:]...)
+	return nil
+}
+
+// nextDeadline returns the earliest time a change notification or a timer
+// is due.
+func (r *runner) nextDeadline() (time.Time, bool) {
+	next, ok := r.nextChange()
+	for _, ctx := range r.context.streamedCtx {
+		if due, has := ctx.nextTimer(); has && (!ok || due.Before(next)) {
+			next, ok = due, true
+		}
+	}
+	return next, ok
+}
@@ -0,0 +1,180 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crdt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+func newTimerTest(t *testing.T, streamed bool) (*changeTest, *CommandContext) {
+	t.Helper()
+	ct := &changeTest{
+		now:     time.Unix(0, 0),
+		counter: NewPNCounter(),
+		stream:  &sendStream{},
+	}
+	clock := func() time.Time { return ct.now }
+	c := &Context{
+		Entity:      &Entity{},
+		crdt:        ct.counter,
+		clock:       clock,
+		streamedCtx: make(map[CommandID]*CommandContext),
+	}
+	ct.runner = &runner{stream: ct.stream, context: c, clock: clock}
+	ctx := c.commandContextFor(&protocol.Command{Id: 1, Streamed: streamed})
+	if streamed {
+		ctx.trackChanges()
+	}
+	return ct, ctx
+}
+
+func (ct *changeTest) fire(t *testing.T, d time.Duration) {
+	t.Helper()
+	ct.now = ct.now.Add(d)
+	if err := ct.runner.fireTimers(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTimers(t *testing.T) {
+	t.Run("a timer fires once when due", func(t *testing.T) {
+		ct, ctx := newTimerTest(t, true)
+		ctx.AfterFunc(time.Second, func(c *CommandContext) (*any.Any, error) {
+			return encoding.Int64(42), nil
+		})
+		if next, ok := ct.runner.nextDeadline(); !ok || !next.Equal(ct.now.Add(time.Second)) {
+			t.Fatalf("nextDeadline(): %v, %v; want: %v", next, ok, ct.now.Add(time.Second))
+		}
+		ct.fire(t, 500*time.Millisecond)
+		ct.fire(t, 500*time.Millisecond)
+		ct.fire(t, time.Second)
+		if got, want := ct.stream.streamedValues(), []int64{42}; !equalValues(got, want) {
+			t.Fatalf("streamed values: %v; want: %v", got, want)
+		}
+		if _, ok := ct.runner.nextDeadline(); ok {
+			t.Fatal("unexpected pending timer")
+		}
+	})
+
+	t.Run("a ticker fires periodically until stopped", func(t *testing.T) {
+		ct, ctx := newTimerTest(t, true)
+		var ticks int64
+		var ticker *Timer
+		ticker = ctx.TickerFunc(time.Second, func(c *CommandContext) (*any.Any, error) {
+			ticks++
+			if ticks == 3 {
+				ticker.Stop()
+			}
+			return encoding.Int64(ticks), nil
+		})
+		for i := 0; i < 5; i++ {
+			ct.fire(t, time.Second)
+		}
+		if got, want := ct.stream.streamedValues(), []int64{1, 2, 3}; !equalValues(got, want) {
+			t.Fatalf("streamed values: %v; want: %v", got, want)
+		}
+	})
+
+	t.Run("a timer ending the stream removes its timers", func(t *testing.T) {
+		ct, ctx := newTimerTest(t, true)
+		ctx.TickerFunc(time.Second, func(c *CommandContext) (*any.Any, error) {
+			c.EndStream()
+			return nil, nil
+		})
+		ct.fire(t, time.Second)
+		if len(ct.stream.sent) != 1 || !ct.stream.sent[0].GetStreamedMessage().GetEndStream() {
+			t.Fatalf("sent: %v; want an ended stream", ct.stream.sent)
+		}
+		if _, ok := ct.runner.nextDeadline(); ok {
+			t.Fatal("unexpected pending timer")
+		}
+	})
+
+	t.Run("a timer ending the stream stops the timers due in the same tick", func(t *testing.T) {
+		ct, ctx := newTimerTest(t, true)
+		ctx.AfterFunc(time.Second, func(c *CommandContext) (*any.Any, error) {
+			c.EndStream()
+			return encoding.Int64(1), nil
+		})
+		ctx.AfterFunc(time.Second, func(c *CommandContext) (*any.Any, error) {
+			return encoding.Int64(2), nil
+		})
+		ct.fire(t, time.Second)
+		if got, want := ct.stream.streamedValues(), []int64{1}; !equalValues(got, want) {
+			t.Fatalf("streamed values: %v; want: %v", got, want)
+		}
+		if len(ct.stream.sent) != 1 || !ct.stream.sent[0].GetStreamedMessage().GetEndStream() {
+			t.Fatalf("sent: %v; want an ended stream", ct.stream.sent)
+		}
+		if _, ok := ct.runner.nextDeadline(); ok {
+			t.Fatal("unexpected pending timer")
+		}
+	})
+
+	t.Run("a timer added by a timer fires on a later tick", func(t *testing.T) {
+		ct, ctx := newTimerTest(t, true)
+		ctx.AfterFunc(time.Second, func(c *CommandContext) (*any.Any, error) {
+			c.AfterFunc(time.Second, func(c *CommandContext) (*any.Any, error) {
+				return encoding.Int64(2), nil
+			})
+			return encoding.Int64(1), nil
+		})
+		ct.fire(t, time.Second)
+		ct.fire(t, time.Second)
+		if got, want := ct.stream.streamedValues(), []int64{1, 2}; !equalValues(got, want) {
+			t.Fatalf("streamed values: %v; want: %v", got, want)
+		}
+	})
+
+	t.Run("a timer fires without a CRDT", func(t *testing.T) {
+		ct, ctx := newTimerTest(t, true)
+		ct.runner.context.crdt = nil
+		ctx.AfterFunc(time.Second, func(c *CommandContext) (*any.Any, error) {
+			return encoding.Int64(1), nil
+		})
+		ct.fire(t, time.Second)
+		if got, want := ct.stream.streamedValues(), []int64{1}; !equalValues(got, want) {
+			t.Fatalf("streamed values: %v; want: %v", got, want)
+		}
+	})
+
+	t.Run("a timer is not allowed to change the CRDT", func(t *testing.T) {
+		ct, ctx := newTimerTest(t, true)
+		ctx.AfterFunc(time.Second, func(c *CommandContext) (*any.Any, error) {
+			ct.counter.Increment(1)
+			return nil, nil
+		})
+		ct.now = ct.now.Add(time.Second)
+		if err := ct.runner.fireTimers(); err != ErrStateChanged {
+			t.Fatalf("fireTimers(): %v; want: %v", err, ErrStateChanged)
+		}
+	})
+
+	t.Run("timers of non-streamed commands are stopped", func(t *testing.T) {
+		ct, ctx := newTimerTest(t, false)
+		if timer := ctx.AfterFunc(time.Second, nil); !timer.stopped {
+			t.Fatal("timer should be stopped")
+		}
+		if _, ok := ct.runner.nextDeadline(); ok {
+			t.Fatal("unexpected pending timer")
+		}
+	})
+}
@@ -0,0 +1,110 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	filedescr "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// eventingField is the field number of the (cloudstate.eventing) method
+// option. The eventing types live in the cloudstate package, which depends
+// on this package, so the option is set by its field number and the type of
+// the eventing is checked by its name.
+const (
+	eventingField protowire.Number      = 1081
+	eventingName  protoreflect.FullName = "cloudstate.Eventing"
+)
+
+// injectEventing sets the eventing option for methods of a service. All of
+// the eventing is checked before any method is changed.
+func (s *EntityDiscoveryServer) injectEventing(serviceName string, eventing map[string]proto.Message) error {
+	options := make(map[*filedescr.MethodDescriptorProto][]byte, len(eventing))
+	for method, e := range eventing {
+		m := s.findMethod(serviceName, method)
+		if m == nil {
+			return fmt.Errorf("unable to configure eventing for unknown method: %q of service: %q", method, serviceName)
+		}
+		if e == nil || proto.MessageReflect(e).Descriptor().FullName() != eventingName {
+			return fmt.Errorf("eventing for method: %q of service: %q has to be a %s, not: %T", method, serviceName, eventingName, e)
+		}
+		value, err := proto.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("unable to marshal eventing for method: %q of service: %q: %w", method, serviceName, err)
+		}
+		options[m] = value
+	}
+	for m, value := range options {
+		if m.Options == nil {
+			m.Options = &filedescr.MethodOptions{}
+		}
+		setOption(proto.MessageReflect(m.Options), eventingField, value)
+	}
+	if len(options) > 0 {
+		return s.updateSpec()
+	}
+	return nil
+}
+
+func (s *EntityDiscoveryServer) findMethod(serviceName, method string) *filedescr.MethodDescriptorProto {
+	for _, f := range s.fileDescriptorSet.File {
+		for _, service := range f.GetService() {
+			if f.GetPackage()+"."+service.GetName() != serviceName {
+				continue
+			}
+			for _, m := range service.GetMethod() {
+				if m.GetName() == method {
+					return m
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// setOption sets a message typed option by its field number, replacing an
+// option set before, whether known or unknown to the options message.
+func setOption(options protoreflect.Message, field protowire.Number, value []byte) {
+	var known []protoreflect.FieldDescriptor
+	options.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if fd.Number() == field {
+			known = append(known, fd)
+		}
+		return true
+	})
+	for _, fd := range known {
+		options.Clear(fd)
+	}
+	unknown := options.GetUnknown()
+	kept := make([]byte, 0, len(unknown)+len(value)+4)
+	for len(unknown) > 0 {
+		num, _, n := protowire.ConsumeField(unknown)
+		if n < 0 {
+			break
+		}
+		if num != field {
+			kept = append(kept, unknown[:n]...)
+		}
+		unknown = unknown[n:]
+	}
+	kept = protowire.AppendTag(kept, field, protowire.BytesType)
+	kept = protowire.AppendBytes(kept, value)
+	options.SetUnknown(kept)
+}
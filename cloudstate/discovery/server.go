@@ -127,6 +127,9 @@ func (s *EntityDiscoveryServer) RegisterEventSourcedEntity(entity *eventsourced.
 	if err := s.resolveFileDescriptors(config); err != nil {
 		return fmt.Errorf("failed to resolve FileDescriptor for DescriptorConfig: %+v: %w", config, err)
 	}
+	if err := s.injectEventing(entity.ServiceName.String(), config.Eventing); err != nil {
+		return err
+	}
 	e := &protocol.Entity{
 		EntityType:    protocol.EventSourced,
 		ServiceName:   entity.ServiceName.String(),
@@ -145,6 +148,9 @@ func (s *EntityDiscoveryServer) RegisterCRDTEntity(entity *crdt.Entity, config p
 	if err := s.resolveFileDescriptors(config); err != nil {
 		return fmt.Errorf("failed to resolveFileDescriptor for DescriptorConfig: %+v: %w", config, err)
 	}
+	if err := s.injectEventing(entity.ServiceName.String(), config.Eventing); err != nil {
+		return err
+	}
 	e := &protocol.Entity{
 		EntityType:  protocol.CRDT,
 		ServiceName: entity.ServiceName.String(),
@@ -164,6 +170,9 @@ func (s *EntityDiscoveryServer) RegisterActionEntity(entity *action.Entity, conf
 	if err := s.resolveFileDescriptors(config); err != nil {
 		return fmt.Errorf("failed to resolveFileDescriptor for DescriptorConfig: %+v: %w", config, err)
 	}
+	if err := s.injectEventing(entity.ServiceName.String(), config.Eventing); err != nil {
+		return err
+	}
 	s.entitySpec.Entities = append(s.entitySpec.Entities, &protocol.Entity{
 		EntityType:  protocol.Action,
 		ServiceName: entity.ServiceName.String(),
@@ -177,6 +186,9 @@ func (s *EntityDiscoveryServer) RegisterValueEntity(entity *value.Entity, config
 	if err := s.resolveFileDescriptors(config); err != nil {
 		return fmt.Errorf("failed to resolveFileDescriptor for DescriptorConfig: %+v: %w", config, err)
 	}
+	if err := s.injectEventing(entity.ServiceName.String(), config.Eventing); err != nil {
+		return err
+	}
 	e := &protocol.Entity{
 		EntityType:    protocol.Value,
 		ServiceName:   entity.ServiceName.String(),
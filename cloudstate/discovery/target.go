@@ -0,0 +1,58 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// ValidateTarget validates that a registered service has the method and that
+// the payload matches the methods input type. Methods taking a
+// google.protobuf.Any accept any payload. It is a protocol.TargetValidator.
+func (s *EntityDiscoveryServer) ValidateTarget(serviceName, commandName string, payload *any.Any) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var found bool
+	for _, f := range s.fileDescriptorSet.File {
+		for _, service := range f.GetService() {
+			if f.GetPackage()+"."+service.GetName() != serviceName {
+				continue
+			}
+			found = true
+			for _, m := range service.GetMethod() {
+				if m.GetName() != commandName {
+					continue
+				}
+				input := strings.TrimPrefix(m.GetInputType(), ".")
+				if input == "google.protobuf.Any" {
+					return nil
+				}
+				typeURL := payload.GetTypeUrl()
+				if name := typeURL[strings.LastIndex(typeURL, "/")+1:]; name != input {
+					return fmt.Errorf("payload of type: %q does not match the input type: %q of method: %q of service: %q", typeURL, input, commandName, serviceName)
+				}
+				return nil
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("unknown service: %q", serviceName)
+	}
+	return fmt.Errorf("unknown method: %q of service: %q", commandName, serviceName)
+}
@@ -0,0 +1,111 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encoding
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// A Codec encodes values of type T to their any.Any representation and
+// decodes them back.
+type Codec[T interface{}] interface {
+	Encode(v T) (*any.Any, error)
+	Decode(a *any.Any) (T, error)
+}
+
+// Codecs for the primitive types Cloudstate supports.
+var (
+	StringCodec  Codec[string]  = primitiveCodec[string]{}
+	Int32Codec   Codec[int32]   = primitiveCodec[int32]{}
+	Int64Codec   Codec[int64]   = primitiveCodec[int64]{}
+	Float32Codec Codec[float32] = primitiveCodec[float32]{}
+	Float64Codec Codec[float64] = primitiveCodec[float64]{}
+	BoolCodec    Codec[bool]    = primitiveCodec[bool]{}
+	BytesCodec   Codec[[]byte]  = primitiveCodec[[]byte]{}
+)
+
+type primitiveCodec[T interface{}] struct{}
+
+func (primitiveCodec[T]) Encode(v T) (*any.Any, error) {
+	return MarshalPrimitive(v)
+}
+
+func (primitiveCodec[T]) Decode(a *any.Any) (T, error) {
+	var zero T
+	i, err := UnmarshalPrimitive(a)
+	if err != nil {
+		return zero, err
+	}
+	v, ok := i.(T)
+	if !ok {
+		return zero, fmt.Errorf("type url: %q does not decode to a %T: %w", a.GetTypeUrl(), zero, ErrNotUnmarshalled)
+	}
+	return v, nil
+}
+
+type protoCodec[T proto.Message] struct {
+	typ reflect.Type
+}
+
+// ProtoCodec returns a Codec for the protobuf message type T. T has to be a
+// pointer to a generated message type.
+func ProtoCodec[T proto.Message]() Codec[T] {
+	var zero T
+	typ := reflect.TypeOf(zero)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("encoding: ProtoCodec needs a pointer to a message type but got: %v", typ))
+	}
+	return protoCodec[T]{typ: typ.Elem()}
+}
+
+func (c protoCodec[T]) Encode(v T) (*any.Any, error) {
+	return MarshalAny(v)
+}
+
+func (c protoCodec[T]) Decode(a *any.Any) (T, error) {
+	m := reflect.New(c.typ).Interface().(T)
+	if name := strings.TrimPrefix(a.GetTypeUrl(), ProtoAnyBase+"/"); name != proto.MessageName(m) {
+		var zero T
+		return zero, fmt.Errorf("type url: %q does not decode to a %s: %w", a.GetTypeUrl(), proto.MessageName(m), ErrNotUnmarshalled)
+	}
+	if err := proto.Unmarshal(a.GetValue(), m); err != nil {
+		var zero T
+		return zero, fmt.Errorf("%s: %w", err, ErrNotUnmarshalled)
+	}
+	return m, nil
+}
+
+type jsonCodec[T interface{}] struct{}
+
+// JSONCodec returns a Codec that encodes values of type T as Cloudstate JSON.
+func JSONCodec[T interface{}]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+func (jsonCodec[T]) Encode(v T) (*any.Any, error) {
+	return MarshalJSON(v)
+}
+
+func (jsonCodec[T]) Decode(a *any.Any) (T, error) {
+	var v T
+	err := UnmarshalJSON(a, &v)
+	return v, err
+}
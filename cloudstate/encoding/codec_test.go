@@ -0,0 +1,96 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encoding
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+)
+
+func TestPrimitiveCodec(t *testing.T) {
+	t.Run("should encode and decode primitives", func(t *testing.T) {
+		s, err := StringCodec.Encode("one")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := StringCodec.Decode(s); err != nil || got != "one" {
+			t.Fatalf("got: %v, %v; want: %v", got, err, "one")
+		}
+		i, err := Int64Codec.Encode(29)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := Int64Codec.Decode(i); err != nil || got != 29 {
+			t.Fatalf("got: %v, %v; want: %v", got, err, 29)
+		}
+		b, err := BytesCodec.Encode([]byte{1, 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := BytesCodec.Decode(b); err != nil || !bytes.Equal(got, []byte{1, 2}) {
+			t.Fatalf("got: %v, %v; want: %v", got, err, []byte{1, 2})
+		}
+	})
+	t.Run("should fail to decode a different primitive", func(t *testing.T) {
+		if _, err := BoolCodec.Decode(String("one")); !errors.Is(err, ErrNotUnmarshalled) {
+			t.Fatalf("got: %v; want: %v", err, ErrNotUnmarshalled)
+		}
+	})
+}
+
+func TestProtoCodec(t *testing.T) {
+	codec := ProtoCodec[*wrappers.StringValue]()
+	t.Run("should encode and decode a message", func(t *testing.T) {
+		a, err := codec.Encode(&wrappers.StringValue{Value: "one"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := codec.Decode(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.GetValue() != "one" {
+			t.Fatalf("got: %v; want: %v", got.GetValue(), "one")
+		}
+	})
+	t.Run("should fail to decode a different message type", func(t *testing.T) {
+		a, err := MarshalAny(&wrappers.Int64Value{Value: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := codec.Decode(a); !errors.Is(err, ErrNotUnmarshalled) {
+			t.Fatalf("got: %v; want: %v", err, ErrNotUnmarshalled)
+		}
+	})
+}
+
+func TestJSONCodec(t *testing.T) {
+	codec := JSONCodec[a]()
+	x, err := codec.Encode(a{B: "29", C: 29})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := codec.Decode(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.B != "29" || got.C != 29 {
+		t.Fatalf("got: %+v; want: %+v", got, a{B: "29", C: 29})
+	}
+}
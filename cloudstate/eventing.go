@@ -0,0 +1,32 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudstate
+
+// TopicSource returns an event source consuming the topic.
+func TopicSource(topic string) *EventSource {
+	return &EventSource{Source: &EventSource_Topic{Topic: topic}}
+}
+
+// EventLogSource returns an event source consuming the event log of the
+// persistence id.
+func EventLogSource(persistenceID string) *EventSource {
+	return &EventSource{Source: &EventSource_EventLog{EventLog: persistenceID}}
+}
+
+// TopicDestination returns an event destination publishing to the topic.
+func TopicDestination(topic string) *EventDestination {
+	return &EventDestination{Destination: &EventDestination_Topic{Topic: topic}}
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/cloudstateio/go-support/cloudstate/encoding"
 	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
 )
 
@@ -31,6 +32,10 @@ type Context struct {
 	Instance EntityHandler
 
 	ctx            context.Context
+	cmdCtx         context.Context
+	interceptors   []protocol.Interceptor
+	targets        *protocol.TargetValidation
+	metadata       *protocol.Metadata
 	events         []interface{}
 	failed         error
 	eventSequence  int64
@@ -67,8 +72,8 @@ func (c *Context) Emit(event interface{}) {
 // run in "blocking" mode, ie. the commands are processed in order, one at a time.
 // The final result of the command handler, either a reply or a forward, is not
 // sent until all synchronous commands are completed.
-func (c *Context) Effect(effect *protocol.SideEffect) {
-	c.sideEffects = append(c.sideEffects, effect)
+func (c *Context) Effect(effects ...*protocol.SideEffect) {
+	c.sideEffects = append(c.sideEffects, effects...)
 }
 
 // Forward sets a protocol.Forward to where a command is forwarded to.
@@ -85,11 +90,58 @@ func (c *Context) Forward(forward *protocol.Forward) {
 	c.forward = forward
 }
 
+// Metadata returns the metadata of the command currently handled. It is
+// empty, not nil, for a command without metadata.
+func (c *Context) Metadata() *protocol.Metadata {
+	if c.metadata == nil {
+		c.metadata = &protocol.Metadata{}
+	}
+	return c.metadata
+}
+
+// CommandCtx returns the context.Context of the command currently handled. It
+// has the deadline of the command, if any, and is done once the command has
+// been handled. It is the context passed on by the interceptors of the
+// command.
+func (c *Context) CommandCtx() context.Context {
+	if c.cmdCtx == nil {
+		return c.ctx
+	}
+	return c.cmdCtx
+}
+
 // StreamCtx returns the context.Context for the contexts' current running stream.
 func (c *Context) StreamCtx() context.Context {
 	return c.ctx
 }
 
+// handleCommand handles a command by the entity instance, intercepted by
+// the interceptors of the entity.
+func (c *Context) handleCommand(cmd *protocol.Command, msg proto.Message) (reply proto.Message, err error) {
+	c.metadata = cmd.Metadata
+	ic := &protocol.InterceptedCommand{
+		Kind:        protocol.EventSourced,
+		ServiceName: c.EventSourcedEntity.ServiceName.String(),
+		EntityID:    cmd.EntityId,
+		Name:        cmd.Name,
+		Message:     msg,
+		Metadata:    cmd.Metadata,
+	}
+	ctx, cancel := protocol.CommandContext(c.ctx, c.EventSourcedEntity.Timeout, cmd.Metadata)
+	defer cancel()
+	c.cmdCtx = ctx
+	err = protocol.Intercept(ctx, ic, c.interceptors, func(ctx context.Context, ic *protocol.InterceptedCommand) error {
+		c.cmdCtx = ctx
+		var err error
+		reply, err = c.Instance.HandleCommand(c, ic.Name, ic.Message)
+		return err
+	})
+	if forward := ic.Forwarded(); forward != nil {
+		c.Forward(forward)
+	}
+	return reply, protocol.TimedOut(c.cmdCtx, err)
+}
+
 func (c *Context) fail(err error) {
 	c.failed = err
 }
@@ -102,6 +154,8 @@ func (c *Context) reset() {
 	c.failed = nil
 	c.forward = nil
 	c.sideEffects = nil
+	c.metadata = nil
+	c.cmdCtx = nil
 }
 
 // marshalEventsAny marshals and the clears events emitted through the context.
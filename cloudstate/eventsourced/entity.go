@@ -45,6 +45,11 @@ type Entity struct {
 	EntityFunc func(id EntityID) EntityHandler
 
 	PassivationStrategy protocol.EntityPassivationStrategy
+	// Interceptors intercept the commands of the entity.
+	Interceptors []protocol.Interceptor
+	// Timeout bounds the time a command is handled if positive. A command
+	// handler overrunning it fails the command.
+	Timeout time.Duration
 }
 
 type Option func(s *Entity)
@@ -67,6 +72,21 @@ func WithPassivationStrategyTimeout(duration time.Duration) Option {
 	}
 }
 
+// WithInterceptors adds interceptors for the commands of the entity.
+func WithInterceptors(interceptors ...protocol.Interceptor) Option {
+	return func(e *Entity) {
+		e.Interceptors = append(e.Interceptors, interceptors...)
+	}
+}
+
+// WithTimeout bounds the time a command of the entity is handled. A
+// grpc-timeout or deadline metadata entry of a command may shorten it.
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Entity) {
+		e.Timeout = timeout
+	}
+}
+
 type (
 	ServiceName string
 	EntityID    string
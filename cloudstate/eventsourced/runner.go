@@ -53,7 +53,10 @@ func (r *runner) handleCommand(cmd *protocol.Command) error {
 		return fmt.Errorf("%s, %w", err, encoding.ErrMarshal)
 	}
 	// The gRPC implementation returns the service method return and an error as a second return value.
-	cmdReply, errReturned := r.context.Instance.HandleCommand(r.context, cmd.Name, message)
+	cmdReply, errReturned := r.context.handleCommand(cmd, message)
+	if errReturned == nil && r.context.failed == nil {
+		errReturned = r.context.targets.Check(r.context.forward, r.context.sideEffects)
+	}
 	// We the take error returned as a client failure except if it's a protocol.ServerError.
 	if errReturned != nil {
 		// If the error is a ServerError, we return this error and the stream will end.
@@ -71,12 +74,15 @@ func (r *runner) handleCommand(cmd *protocol.Command) error {
 	if r.context.failed != nil {
 		return r.context.failed
 	}
-	// Get the reply.
-	reply, err := encoding.MarshalAny(cmdReply)
-	if err != nil { // this should never happen
-		return protocol.ServerError{
-			Failure: &protocol.Failure{CommandId: cmd.GetId()},
-			Err:     fmt.Errorf("marshalling of reply failed: %w", err),
+	// Get the reply, an interceptor may have forwarded the command without one.
+	var reply *any.Any
+	if cmdReply != nil || r.context.forward == nil {
+		reply, err = encoding.MarshalAny(cmdReply)
+		if err != nil { // this should never happen
+			return protocol.ServerError{
+				Failure: &protocol.Failure{CommandId: cmd.GetId()},
+				Err:     fmt.Errorf("marshalling of reply failed: %w", err),
+			}
 		}
 	}
 	// Get the events emitted.
@@ -37,6 +37,11 @@ type Server struct {
 	// entities are indexed by their service name.
 	entities map[ServiceName]*Entity
 
+	// interceptors intercept commands of all entities.
+	interceptors []protocol.Interceptor
+	// targets validates forward and side effect targets, if set.
+	targets *protocol.TargetValidation
+
 	entity.UnimplementedEventSourcedServer
 }
 
@@ -47,6 +52,34 @@ func NewServer() *Server {
 	}
 }
 
+// Intercept adds interceptors for commands of all entities of the server.
+// They run before the interceptors of an entity.
+func (s *Server) Intercept(interceptors ...protocol.Interceptor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+func (s *Server) globalInterceptors() []protocol.Interceptor {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.interceptors
+}
+
+// ValidateTargets sets the validation of forward and side effect targets
+// for all entities of the server.
+func (s *Server) ValidateTargets(v *protocol.TargetValidation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets = v
+}
+
+func (s *Server) targetValidation() *protocol.TargetValidation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.targets
+}
+
 // Register registers an Entity a an event sourced entity for CloudState.
 func (s *Server) Register(entity *Entity) error {
 	if entity.EntityFunc == nil {
@@ -187,6 +220,8 @@ func (s *Server) handleInit(init *entity.EventSourcedInit, r *runner) error {
 		eventSequence:      0,
 		ctx:                r.stream.Context(),
 	}
+	r.context.interceptors = protocol.Interceptors(s.globalInterceptors(), e.Interceptors)
+	r.context.targets = s.targetValidation()
 	if snapshot := init.GetSnapshot(); snapshot != nil {
 		if err := r.handleInitSnapshot(snapshot); err != nil {
 			return err
@@ -0,0 +1,210 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// CloudEvents attributes as carried by metadata in the binary content mode.
+const (
+	CloudEventSpecVersion     = "ce-specversion"
+	CloudEventID              = "ce-id"
+	CloudEventSource          = "ce-source"
+	CloudEventType            = "ce-type"
+	CloudEventSubject         = "ce-subject"
+	CloudEventTime            = "ce-time"
+	CloudEventDataContentType = "Content-Type"
+)
+
+// cloudEventPrefix prefixes the keys of CloudEvents attributes in metadata.
+const cloudEventPrefix = "ce-"
+
+// Get returns the first string value for the key. Keys are compared case
+// insensitive.
+func (x *Metadata) Get(key string) (string, bool) {
+	for _, entry := range x.GetEntries() {
+		if strings.EqualFold(entry.GetKey(), key) {
+			return entry.GetStringValue(), true
+		}
+	}
+	return "", false
+}
+
+// Set sets the string value for the key, replacing all existing values.
+// As nil metadata has nowhere to keep the value, x must not be nil. The
+// metadata returned by the contexts of entities never is.
+func (x *Metadata) Set(key string, value string) {
+	x.Delete(key)
+	x.Entries = append(x.Entries, &MetadataEntry{
+		Key:   key,
+		Value: &MetadataEntry_StringValue{StringValue: value},
+	})
+}
+
+// Delete removes all values for the key. It is a `no operation` for nil
+// metadata.
+func (x *Metadata) Delete(key string) {
+	if x == nil {
+		return
+	}
+	entries := x.Entries[:0]
+	for _, entry := range x.Entries {
+		if !strings.EqualFold(entry.GetKey(), key) {
+			entries = append(entries, entry)
+		}
+	}
+	x.Entries = entries
+}
+
+// WithoutCloudEvent returns a copy of the metadata without the CloudEvents
+// attributes, the entries with keys prefixed by "ce-". It is empty, not nil,
+// for nil metadata.
+func (x *Metadata) WithoutCloudEvent() *Metadata {
+	md := &Metadata{}
+	for _, entry := range x.GetEntries() {
+		key := entry.GetKey()
+		if len(key) >= len(cloudEventPrefix) && strings.EqualFold(key[:len(cloudEventPrefix)], cloudEventPrefix) {
+			continue
+		}
+		md.Entries = append(md.Entries, proto.Clone(entry).(*MetadataEntry))
+	}
+	return md
+}
+
+// A CloudEvent is a view on metadata carrying CloudEvents attributes.
+type CloudEvent struct {
+	// Metadata carries the attributes. It is created by the first setter
+	// called if nil.
+	Metadata *Metadata
+}
+
+// CloudEvent returns a CloudEvents view on the metadata. For nil metadata
+// the view creates its own on the first set.
+func (x *Metadata) CloudEvent() *CloudEvent {
+	return &CloudEvent{Metadata: x}
+}
+
+// NewCloudEvent returns a CloudEvent of spec version 1.0 with the required
+// attributes set.
+func NewCloudEvent(id, source, eventType string) *CloudEvent {
+	e := &CloudEvent{}
+	e.set(CloudEventSpecVersion, "1.0")
+	e.SetID(id)
+	e.SetSource(source)
+	e.SetType(eventType)
+	return e
+}
+
+func (e *CloudEvent) get(key string) string {
+	value, _ := e.Metadata.Get(key)
+	return value
+}
+
+func (e *CloudEvent) set(key string, value string) {
+	if e.Metadata == nil {
+		e.Metadata = &Metadata{}
+	}
+	e.Metadata.Set(key, value)
+}
+
+// IsCloudEvent returns whether the metadata carries the attributes
+// required for a CloudEvent.
+func (e *CloudEvent) IsCloudEvent() bool {
+	for _, key := range []string{CloudEventSpecVersion, CloudEventID, CloudEventSource, CloudEventType} {
+		if _, ok := e.Metadata.Get(key); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// SpecVersion returns the CloudEvents spec version.
+func (e *CloudEvent) SpecVersion() string {
+	return e.get(CloudEventSpecVersion)
+}
+
+// ID returns the id of the event.
+func (e *CloudEvent) ID() string {
+	return e.get(CloudEventID)
+}
+
+// SetID sets the id of the event.
+func (e *CloudEvent) SetID(id string) {
+	e.set(CloudEventID, id)
+}
+
+// Source returns the source of the event.
+func (e *CloudEvent) Source() string {
+	return e.get(CloudEventSource)
+}
+
+// SetSource sets the source of the event.
+func (e *CloudEvent) SetSource(source string) {
+	e.set(CloudEventSource, source)
+}
+
+// Type returns the type of the event.
+func (e *CloudEvent) Type() string {
+	return e.get(CloudEventType)
+}
+
+// SetType sets the type of the event.
+func (e *CloudEvent) SetType(eventType string) {
+	e.set(CloudEventType, eventType)
+}
+
+// Subject returns the subject of the event.
+func (e *CloudEvent) Subject() string {
+	return e.get(CloudEventSubject)
+}
+
+// SetSubject sets the subject of the event.
+func (e *CloudEvent) SetSubject(subject string) {
+	e.set(CloudEventSubject, subject)
+}
+
+// Time returns the time of the event. It returns false if the time is
+// not set or invalid.
+func (e *CloudEvent) Time() (time.Time, bool) {
+	value, ok := e.Metadata.Get(CloudEventTime)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// SetTime sets the time of the event.
+func (e *CloudEvent) SetTime(t time.Time) {
+	e.set(CloudEventTime, t.UTC().Format(time.RFC3339Nano))
+}
+
+// DataContentType returns the content type of the events data.
+func (e *CloudEvent) DataContentType() string {
+	return e.get(CloudEventDataContentType)
+}
+
+// SetDataContentType sets the content type of the events data.
+func (e *CloudEvent) SetDataContentType(contentType string) {
+	e.set(CloudEventDataContentType, contentType)
+}
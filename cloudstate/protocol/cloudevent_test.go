@@ -0,0 +1,94 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCloudEvent(t *testing.T) {
+	t.Run("should read attributes case insensitive", func(t *testing.T) {
+		md := &Metadata{Entries: []*MetadataEntry{
+			{Key: "CE-Subject", Value: &MetadataEntry_StringValue{StringValue: "subject"}},
+			{Key: "content-type", Value: &MetadataEntry_StringValue{StringValue: "application/json"}},
+		}}
+		ce := md.CloudEvent()
+		if got := ce.Subject(); got != "subject" {
+			t.Fatalf("ce.Subject(): %v; want: %v", got, "subject")
+		}
+		if got := ce.DataContentType(); got != "application/json" {
+			t.Fatalf("ce.DataContentType(): %v; want: %v", got, "application/json")
+		}
+		if ce.IsCloudEvent() {
+			t.Fatal("ce.IsCloudEvent() should be false without the required attributes")
+		}
+	})
+
+	t.Run("should read attributes of nil metadata", func(t *testing.T) {
+		var md *Metadata
+		ce := md.CloudEvent()
+		if got := ce.ID(); got != "" {
+			t.Fatalf("ce.ID(): %v; want: %v", got, "")
+		}
+		if _, ok := ce.Time(); ok {
+			t.Fatal("ce.Time() should not be set")
+		}
+	})
+
+	t.Run("should delete from nil metadata", func(t *testing.T) {
+		var md *Metadata
+		md.Delete(CloudEventID)
+	})
+
+	t.Run("should copy metadata without attributes", func(t *testing.T) {
+		md := &Metadata{Entries: []*MetadataEntry{
+			{Key: "CE-Subject", Value: &MetadataEntry_StringValue{StringValue: "subject"}},
+			{Key: "tenant", Value: &MetadataEntry_StringValue{StringValue: "t"}},
+		}}
+		c := md.WithoutCloudEvent()
+		if len(c.GetEntries()) != 1 || c.GetEntries()[0].GetKey() != "tenant" {
+			t.Fatalf("md.WithoutCloudEvent(): %v; want the tenant only", c)
+		}
+		c.Set("tenant", "u")
+		if v, _ := md.Get("tenant"); v != "t" {
+			t.Fatalf("md.Get(tenant): %v; want: %v", v, "t")
+		}
+		if c := (*Metadata)(nil).WithoutCloudEvent(); c == nil {
+			t.Fatal("the copy of nil metadata should not be nil")
+		}
+	})
+
+	t.Run("should set attributes", func(t *testing.T) {
+		ce := NewCloudEvent("1", "/source", "com.example.event")
+		now := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+		ce.SetTime(now)
+		ce.SetSubject("one")
+		ce.SetSubject("two")
+		if !ce.IsCloudEvent() {
+			t.Fatal("ce.IsCloudEvent() should be true")
+		}
+		if got := ce.Subject(); got != "two" {
+			t.Fatalf("ce.Subject(): %v; want: %v", got, "two")
+		}
+		if got, ok := ce.Time(); !ok || !got.Equal(now) {
+			t.Fatalf("ce.Time(): %v, %v; want: %v", got, ok, now)
+		}
+		if got, want := len(ce.Metadata.GetEntries()), 6; got != want {
+			t.Fatalf("len(entries): %v; want: %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	// GrpcTimeout is the metadata entry of a gRPC timeout, formatted as
+	// specified by the gRPC over HTTP2 protocol, e.g. "100m" for 100ms.
+	GrpcTimeout = "grpc-timeout"
+	// Deadline is the metadata entry of an absolute deadline formatted as
+	// RFC3339.
+	Deadline = "deadline"
+)
+
+// ErrCommandTimeout is the error of a command whose handler did not finish
+// before its deadline.
+var ErrCommandTimeout = errors.New("command timed out")
+
+// CommandDeadline returns the deadline of a command started at now. It is
+// the earliest of now plus timeout, if positive, and the deadline of a
+// grpc-timeout or deadline metadata entry.
+func CommandDeadline(now time.Time, timeout time.Duration, md *Metadata) (deadline time.Time, ok bool) {
+	earliest := func(d time.Time) {
+		if !ok || d.Before(deadline) {
+			deadline, ok = d, true
+		}
+	}
+	if timeout > 0 {
+		earliest(now.Add(timeout))
+	}
+	if v, found := md.Get(GrpcTimeout); found {
+		if t, err := ParseGrpcTimeout(v); err == nil {
+			earliest(now.Add(t))
+		}
+	}
+	if v, found := md.Get(Deadline); found {
+		if d, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			earliest(d)
+		}
+	}
+	return deadline, ok
+}
+
+// CommandContext returns a context for handling a command derived from
+// parent, with the deadline of the command if it has one. The returned
+// cancel func has to be called once the command is handled.
+func CommandContext(parent context.Context, timeout time.Duration, md *Metadata) (context.Context, context.CancelFunc) {
+	if deadline, ok := CommandDeadline(time.Now(), timeout, md); ok {
+		return context.WithDeadline(parent, deadline)
+	}
+	return context.WithCancel(parent)
+}
+
+// TimedOut returns a ClientError of ErrCommandTimeout if the deadline of ctx
+// has been exceeded, otherwise err. A handler that overruns its deadline
+// fails the command regardless of its result.
+func TimedOut(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ClientError{Err: ErrCommandTimeout}
+	}
+	return err
+}
+
+// ParseGrpcTimeout parses a gRPC timeout, a positive integer of at most
+// eight digits followed by one of the units H, M, S, m, u or n.
+func ParseGrpcTimeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, fmt.Errorf("invalid grpc-timeout: %q", v)
+	}
+	var unit time.Duration
+	switch v[len(v)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, fmt.Errorf("invalid grpc-timeout unit: %q", v)
+	}
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid grpc-timeout: %q", v)
+	}
+	if n > math.MaxInt64/int64(unit) {
+		return math.MaxInt64, nil
+	}
+	return time.Duration(n) * unit, nil
+}
@@ -0,0 +1,95 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseGrpcTimeout(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  time.Duration
+		err   bool
+	}{
+		{"100m", 100 * time.Millisecond, false},
+		{"5S", 5 * time.Second, false},
+		{"2H", 2 * time.Hour, false},
+		{"99999999H", time.Duration(1<<63 - 1), false},
+		{"1", 0, true},
+		{"10s", 0, true},
+		{"-1S", 0, true},
+		{"123456789S", 0, true},
+	} {
+		got, err := ParseGrpcTimeout(tc.value)
+		if (err != nil) != tc.err {
+			t.Fatalf("ParseGrpcTimeout(%q) err: %v; want err: %v", tc.value, err, tc.err)
+		}
+		if got != tc.want {
+			t.Fatalf("ParseGrpcTimeout(%q): %v; want: %v", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestCommandDeadline(t *testing.T) {
+	now := time.Unix(0, 0).UTC()
+	md := func(key, value string) *Metadata {
+		m := &Metadata{}
+		m.Set(key, value)
+		return m
+	}
+
+	t.Run("should have no deadline without a timeout", func(t *testing.T) {
+		if d, ok := CommandDeadline(now, 0, nil); ok {
+			t.Fatalf("deadline: %v; want none", d)
+		}
+	})
+
+	t.Run("should use the earliest deadline", func(t *testing.T) {
+		for _, tc := range []struct {
+			name    string
+			timeout time.Duration
+			md      *Metadata
+			want    time.Time
+		}{
+			{"timeout", time.Second, nil, now.Add(time.Second)},
+			{"grpc-timeout", time.Second, md(GrpcTimeout, "100m"), now.Add(100 * time.Millisecond)},
+			{"deadline", time.Second, md(Deadline, now.Add(time.Millisecond).Format(time.RFC3339Nano)), now.Add(time.Millisecond)},
+			{"invalid metadata", time.Second, md(GrpcTimeout, "soon"), now.Add(time.Second)},
+		} {
+			d, ok := CommandDeadline(now, tc.timeout, tc.md)
+			if !ok || !d.Equal(tc.want) {
+				t.Fatalf("%s: deadline: %v, %v; want: %v", tc.name, d, ok, tc.want)
+			}
+		}
+	})
+}
+
+func TestTimedOut(t *testing.T) {
+	ctx, cancel := CommandContext(context.Background(), time.Nanosecond, nil)
+	defer cancel()
+	<-ctx.Done()
+	if err := TimedOut(ctx, nil); !errors.Is(err, ClientError{}) || !errors.Is(err, ErrCommandTimeout) {
+		t.Fatalf("err: %v; want a client error of ErrCommandTimeout", err)
+	}
+	err := errors.New("failed")
+	if got := TimedOut(context.Background(), err); got != err {
+		t.Fatalf("err: %v; want: %v", got, err)
+	}
+}
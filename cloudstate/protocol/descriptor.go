@@ -1,6 +1,9 @@
 package protocol
 
-import "github.com/golang/protobuf/descriptor"
+import (
+	"github.com/golang/protobuf/descriptor"
+	"github.com/golang/protobuf/proto"
+)
 
 // Config go get a CloudState instance configured.
 type Config struct {
@@ -13,6 +16,10 @@ type DescriptorConfig struct {
 	Service        string
 	Domain         []string
 	DomainMessages []descriptor.Message
+	// Eventing configures the eventing of methods of the service by their
+	// name. It is injected as the (cloudstate.eventing) method option and
+	// replaces the option set in the services proto.
+	Eventing map[string]proto.Message
 }
 
 func (dc DescriptorConfig) AddDomainMessage(m descriptor.Message) DescriptorConfig {
@@ -24,3 +31,16 @@ func (dc DescriptorConfig) AddDomainDescriptor(filename ...string) DescriptorCon
 	dc.Domain = append(dc.Domain, filename...)
 	return dc
 }
+
+// AddEventing sets the eventing for a method of the service. The eventing
+// has to be a cloudstate.Eventing message, registering the entity fails
+// otherwise.
+func (dc DescriptorConfig) AddEventing(method string, eventing proto.Message) DescriptorConfig {
+	m := make(map[string]proto.Message, len(dc.Eventing)+1)
+	for k, v := range dc.Eventing {
+		m[k] = v
+	}
+	m[method] = eventing
+	dc.Eventing = m
+	return dc
+}
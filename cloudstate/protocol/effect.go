@@ -0,0 +1,132 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// An EffectBuilder builds side effects and forwards to a gRPC method.
+type EffectBuilder struct {
+	method      protoreflect.MethodDescriptor
+	synchronous bool
+	metadata    *Metadata
+}
+
+// EffectFor returns an EffectBuilder for the method, for example taken from
+// the file descriptor of a generated client.
+func EffectFor(method protoreflect.MethodDescriptor) *EffectBuilder {
+	return &EffectBuilder{method: method}
+}
+
+// EffectForMethod returns an EffectBuilder for the method of the fully
+// qualified name, like "com.example.ShoppingCart.AddItem".
+func EffectForMethod(fullName protoreflect.FullName) (*EffectBuilder, error) {
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(fullName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find method: %q: %w", fullName, err)
+	}
+	method, ok := d.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("descriptor: %q is not a method", fullName)
+	}
+	return EffectFor(method), nil
+}
+
+// Synchronous marks side effects to be performed synchronously.
+func (b *EffectBuilder) Synchronous() *EffectBuilder {
+	b.synchronous = true
+	return b
+}
+
+// Metadata sets the metadata of side effects and forwards.
+func (b *EffectBuilder) Metadata(md *Metadata) *EffectBuilder {
+	b.metadata = md
+	return b
+}
+
+// SideEffect returns a side effect calling the method with msg.
+func (b *EffectBuilder) SideEffect(msg proto.Message) (*SideEffect, error) {
+	payload, err := b.payload(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &SideEffect{
+		ServiceName: b.serviceName(),
+		CommandName: b.commandName(),
+		Payload:     payload,
+		Synchronous: b.synchronous,
+		Metadata:    b.metadata,
+	}, nil
+}
+
+// FanOut returns a side effect per message, typically one for each entity
+// the command is fanned out to.
+func (b *EffectBuilder) FanOut(msgs ...proto.Message) ([]*SideEffect, error) {
+	effects := make([]*SideEffect, 0, len(msgs))
+	for _, msg := range msgs {
+		effect, err := b.SideEffect(msg)
+		if err != nil {
+			return nil, err
+		}
+		effects = append(effects, effect)
+	}
+	return effects, nil
+}
+
+// Forward returns a forward calling the method with msg.
+func (b *EffectBuilder) Forward(msg proto.Message) (*Forward, error) {
+	payload, err := b.payload(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &Forward{
+		ServiceName: b.serviceName(),
+		CommandName: b.commandName(),
+		Payload:     payload,
+		Metadata:    b.metadata,
+	}, nil
+}
+
+func (b *EffectBuilder) serviceName() string {
+	return string(b.method.Parent().FullName())
+}
+
+func (b *EffectBuilder) commandName() string {
+	return string(b.method.Name())
+}
+
+// payload validates msg to be of the methods input type and marshals it.
+func (b *EffectBuilder) payload(msg proto.Message) (*any.Any, error) {
+	if b.method == nil {
+		return nil, errors.New("no method given for the effect")
+	}
+	if msg == nil {
+		return nil, fmt.Errorf("no message given for method: %q", b.method.FullName())
+	}
+	input := b.method.Input().FullName()
+	if name := proto.MessageReflect(msg).Descriptor().FullName(); name != input {
+		return nil, fmt.Errorf("message of type: %q does not match the input type: %q of method: %q", name, input, b.method.FullName())
+	}
+	return encoding.MarshalAny(msg)
+}
@@ -0,0 +1,86 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"testing"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+)
+
+func TestEffectBuilder(t *testing.T) {
+	discover := File_entity_proto.Services().ByName("EntityDiscovery").Methods().ByName("discover")
+
+	t.Run("should build a side effect", func(t *testing.T) {
+		md := &Metadata{}
+		effect, err := EffectFor(discover).Synchronous().Metadata(md).SideEffect(&ProxyInfo{ProxyName: "proxy"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if effect.GetServiceName() != "cloudstate.EntityDiscovery" || effect.GetCommandName() != "discover" {
+			t.Fatalf("effect: %v; want a side effect to cloudstate.EntityDiscovery.discover", effect)
+		}
+		if !effect.GetSynchronous() || effect.GetMetadata() != md {
+			t.Fatalf("effect: %v; want a synchronous side effect with metadata", effect)
+		}
+		info := &ProxyInfo{}
+		if err := encoding.UnmarshalAny(effect.GetPayload(), info); err != nil {
+			t.Fatal(err)
+		}
+		if info.GetProxyName() != "proxy" {
+			t.Fatalf("info.GetProxyName(): %v; want: %v", info.GetProxyName(), "proxy")
+		}
+	})
+
+	t.Run("should build a forward by the methods name", func(t *testing.T) {
+		b, err := EffectForMethod("cloudstate.EntityDiscovery.discover")
+		if err != nil {
+			t.Fatal(err)
+		}
+		forward, err := b.Forward(&ProxyInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if forward.GetServiceName() != "cloudstate.EntityDiscovery" || forward.GetCommandName() != "discover" {
+			t.Fatalf("forward: %v; want a forward to cloudstate.EntityDiscovery.discover", forward)
+		}
+	})
+
+	t.Run("should fail for an unknown method", func(t *testing.T) {
+		if _, err := EffectForMethod("cloudstate.EntityDiscovery.unknown"); err == nil {
+			t.Fatal("EffectForMethod() should have failed")
+		}
+	})
+
+	t.Run("should fail for a message not of the input type", func(t *testing.T) {
+		if _, err := EffectFor(discover).SideEffect(&Metadata{}); err == nil {
+			t.Fatal("SideEffect() should have failed")
+		}
+	})
+
+	t.Run("should fan out to side effects", func(t *testing.T) {
+		effects, err := EffectFor(discover).FanOut(&ProxyInfo{ProxyName: "one"}, &ProxyInfo{ProxyName: "two"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(effects) != 2 {
+			t.Fatalf("len(effects): %v; want: %v", len(effects), 2)
+		}
+		if _, err := EffectFor(discover).FanOut(&ProxyInfo{}, &Metadata{}); err == nil {
+			t.Fatal("FanOut() should have failed")
+		}
+	})
+}
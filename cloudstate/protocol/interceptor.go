@@ -0,0 +1,82 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// An InterceptedCommand describes a command passed through an interceptor
+// chain.
+type InterceptedCommand struct {
+	// Kind is the kind of entity handling the command, one of EventSourced,
+	// CRDT, Action or Value.
+	Kind string
+	// ServiceName is the name of the service the command is for.
+	ServiceName string
+	// EntityID is the ID of the entity the command is for, empty for actions.
+	EntityID string
+	// Name is the name of the command.
+	Name string
+	// Message is the decoded message of the command. It is nil for full
+	// duplex streamed actions.
+	Message proto.Message
+	// Metadata is the metadata of the command.
+	Metadata *Metadata
+
+	forward *Forward
+}
+
+// Forward forwards the command instead of replying to it. An interceptor
+// may forward a command without calling the next handler.
+func (c *InterceptedCommand) Forward(forward *Forward) {
+	c.forward = forward
+}
+
+// Forwarded returns the forward set by an interceptor, if any.
+func (c *InterceptedCommand) Forwarded() *Forward {
+	return c.forward
+}
+
+// A CommandHandler handles an intercepted command.
+type CommandHandler func(ctx context.Context, cmd *InterceptedCommand) error
+
+// An Interceptor intercepts a command before it is handled by an entity.
+// It calls next to continue the chain or short-circuits it by returning an
+// error or forwarding the command. Returned ClientErrors are reported as
+// failures to the client.
+type Interceptor func(ctx context.Context, cmd *InterceptedCommand, next CommandHandler) error
+
+// Intercept handles a command by the interceptors given, where the first
+// interceptor is the outermost one, and the handler h at the end.
+func Intercept(ctx context.Context, cmd *InterceptedCommand, interceptors []Interceptor, h CommandHandler) error {
+	if len(interceptors) == 0 {
+		return h(ctx, cmd)
+	}
+	return interceptors[0](ctx, cmd, func(ctx context.Context, cmd *InterceptedCommand) error {
+		return Intercept(ctx, cmd, interceptors[1:], h)
+	})
+}
+
+// Interceptors returns the interceptors of a server followed by those of
+// an entity.
+func Interceptors(server []Interceptor, entity []Interceptor) []Interceptor {
+	all := make([]Interceptor, 0, len(server)+len(entity))
+	all = append(all, server...)
+	return append(all, entity...)
+}
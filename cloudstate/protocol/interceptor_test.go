@@ -0,0 +1,83 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIntercept(t *testing.T) {
+	record := func(calls *[]string, name string) Interceptor {
+		return func(ctx context.Context, cmd *InterceptedCommand, next CommandHandler) error {
+			*calls = append(*calls, name)
+			return next(ctx, cmd)
+		}
+	}
+
+	t.Run("should run interceptors in order before the handler", func(t *testing.T) {
+		calls := make([]string, 0)
+		interceptors := Interceptors(
+			[]Interceptor{record(&calls, "global")},
+			[]Interceptor{record(&calls, "entity")},
+		)
+		err := Intercept(context.Background(), &InterceptedCommand{}, interceptors, func(context.Context, *InterceptedCommand) error {
+			calls = append(calls, "handler")
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"global", "entity", "handler"}; !reflect.DeepEqual(calls, want) {
+			t.Fatalf("calls: %v; want: %v", calls, want)
+		}
+	})
+
+	t.Run("should short-circuit with a failure", func(t *testing.T) {
+		errDenied := ClientError{Err: errors.New("denied")}
+		deny := func(context.Context, *InterceptedCommand, CommandHandler) error {
+			return errDenied
+		}
+		err := Intercept(context.Background(), &InterceptedCommand{}, []Interceptor{deny}, func(context.Context, *InterceptedCommand) error {
+			t.Fatal("the handler should not have been called")
+			return nil
+		})
+		if !errors.Is(err, ClientError{}) {
+			t.Fatalf("err: %v; want a client error", err)
+		}
+	})
+
+	t.Run("should short-circuit with a forward", func(t *testing.T) {
+		forward := &Forward{ServiceName: "other", CommandName: "Call"}
+		redirect := func(_ context.Context, cmd *InterceptedCommand, _ CommandHandler) error {
+			cmd.Forward(forward)
+			return nil
+		}
+		cmd := &InterceptedCommand{}
+		err := Intercept(context.Background(), cmd, []Interceptor{redirect}, func(context.Context, *InterceptedCommand) error {
+			t.Fatal("the handler should not have been called")
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cmd.Forwarded() != forward {
+			t.Fatalf("cmd.Forwarded(): %v; want: %v", cmd.Forwarded(), forward)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+// A TargetValidator validates that a service method can be called with the
+// payload given.
+type TargetValidator func(serviceName, commandName string, payload *any.Any) error
+
+// TargetValidation validates the targets of forwards and side effects
+// before a reply is sent.
+type TargetValidation struct {
+	Validate TargetValidator
+	// Strict reports invalid targets as a ServerError, which ends the
+	// entities stream, instead of failing the command with a ClientError.
+	Strict bool
+}
+
+// Check validates the targets of a forward and side effects. It does not
+// validate anything for a nil TargetValidation.
+func (v *TargetValidation) Check(forward *Forward, effects []*SideEffect) error {
+	if v == nil || v.Validate == nil {
+		return nil
+	}
+	if forward != nil {
+		if err := v.Validate(forward.GetServiceName(), forward.GetCommandName(), forward.GetPayload()); err != nil {
+			return v.error(fmt.Errorf("invalid forward: %w", err))
+		}
+	}
+	for _, effect := range effects {
+		if err := v.Validate(effect.GetServiceName(), effect.GetCommandName(), effect.GetPayload()); err != nil {
+			return v.error(fmt.Errorf("invalid side effect: %w", err))
+		}
+	}
+	return nil
+}
+
+func (v *TargetValidation) error(err error) error {
+	if v.Strict {
+		return ServerError{Err: err}
+	}
+	return ClientError{Err: err}
+}
@@ -0,0 +1,57 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package protocol
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+func TestTargetValidation(t *testing.T) {
+	validate := func(serviceName, commandName string, _ *any.Any) error {
+		if serviceName != "known" {
+			return errors.New("unknown service")
+		}
+		return nil
+	}
+
+	t.Run("should not validate without a validator", func(t *testing.T) {
+		var v *TargetValidation
+		if err := v.Check(&Forward{ServiceName: "unknown"}, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("should fail an invalid forward with a client error", func(t *testing.T) {
+		v := &TargetValidation{Validate: validate}
+		if err := v.Check(&Forward{ServiceName: "known"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Check(&Forward{ServiceName: "unknown"}, nil); !errors.Is(err, ClientError{}) {
+			t.Fatalf("err: %v; want a client error", err)
+		}
+	})
+
+	t.Run("should fail an invalid side effect with a server error in strict mode", func(t *testing.T) {
+		v := &TargetValidation{Validate: validate, Strict: true}
+		effects := []*SideEffect{{ServiceName: "known"}, {ServiceName: "unknown"}}
+		if err := v.Check(nil, effects); !errors.Is(err, ServerError{}) {
+			t.Fatalf("err: %v; want a server error", err)
+		}
+	})
+}
@@ -35,6 +35,14 @@ type Context struct {
 	Instance EntityHandler
 	// ctx is the context.Context from the stream this context is assigned to.
 	ctx context.Context
+	// cmdCtx is the context.Context of the command currently handled.
+	cmdCtx context.Context
+	// interceptors intercept commands of the entity.
+	interceptors []protocol.Interceptor
+	// targets validates forward and side effect targets, if set.
+	targets *protocol.TargetValidation
+	// metadata is the metadata of the command currently handled.
+	metadata *protocol.Metadata
 
 	update      bool
 	delete      bool
@@ -42,6 +50,8 @@ type Context struct {
 	failure     error
 	sideEffects []*protocol.SideEffect
 	state       *any.Any
+	// migrated is set if the state was migrated and has to be written back.
+	migrated bool
 }
 
 func (c *Context) Forward(forward *protocol.Forward) {
@@ -49,8 +59,28 @@ func (c *Context) Forward(forward *protocol.Forward) {
 	c.failure = nil
 }
 
-func (c *Context) SideEffect(effect *protocol.SideEffect) {
-	c.sideEffects = append(c.sideEffects, effect)
+// Metadata returns the metadata of the command currently handled. It is
+// empty, not nil, for a command without metadata.
+func (c *Context) Metadata() *protocol.Metadata {
+	if c.metadata == nil {
+		c.metadata = &protocol.Metadata{}
+	}
+	return c.metadata
+}
+
+// CommandCtx returns the context.Context of the command currently handled. It
+// has the deadline of the command, if any, and is done once the command has
+// been handled. It is the context passed on by the interceptors of the
+// command.
+func (c *Context) CommandCtx() context.Context {
+	if c.cmdCtx == nil {
+		return c.ctx
+	}
+	return c.cmdCtx
+}
+
+func (c *Context) SideEffect(effects ...*protocol.SideEffect) {
+	c.sideEffects = append(c.sideEffects, effects...)
 }
 
 func (c *Context) entityReply(command *protocol.Command, reply *any.Any) *entity.ValueEntityReply {
@@ -152,7 +182,7 @@ func (c *Context) runCommand(cmd *protocol.Command) (*any.Any, error) {
 	// unmarshal the commands message
 	msgName := strings.TrimPrefix(cmd.GetPayload().GetTypeUrl(), "type.googleapis.com/")
 	if strings.HasPrefix(msgName, "json.cloudstate.io/") {
-		return c.Instance.HandleCommand(c, cmd.Name, cmd.Payload)
+		return c.handleCommand(cmd, cmd.Payload)
 	}
 	messageType := proto.MessageType(msgName)
 	message, ok := reflect.New(messageType.Elem()).Interface().(proto.Message)
@@ -162,7 +192,42 @@ func (c *Context) runCommand(cmd *protocol.Command) (*any.Any, error) {
 	if err := proto.Unmarshal(cmd.Payload.Value, message); err != nil {
 		return nil, err
 	}
-	return c.Instance.HandleCommand(c, cmd.Name, message)
+	return c.handleCommand(cmd, message)
+}
+
+// handleCommand handles a command by the entity instance, intercepted by
+// the interceptors of the entity.
+func (c *Context) handleCommand(cmd *protocol.Command, msg proto.Message) (reply *any.Any, err error) {
+	c.metadata = cmd.Metadata
+	ic := &protocol.InterceptedCommand{
+		Kind:        protocol.Value,
+		ServiceName: c.Entity.ServiceName.String(),
+		EntityID:    cmd.EntityId,
+		Name:        cmd.Name,
+		Message:     msg,
+		Metadata:    cmd.Metadata,
+	}
+	ctx, cancel := protocol.CommandContext(c.ctx, c.Entity.Timeout, cmd.Metadata)
+	defer cancel()
+	c.cmdCtx = ctx
+	state := c.state
+	err = protocol.Intercept(ctx, ic, c.interceptors, func(ctx context.Context, ic *protocol.InterceptedCommand) error {
+		c.cmdCtx = ctx
+		var err error
+		reply, err = c.Instance.HandleCommand(c, ic.Name, ic.Message)
+		return err
+	})
+	if forward := ic.Forwarded(); forward != nil {
+		c.Forward(forward)
+	}
+	if timedOut := protocol.TimedOut(c.cmdCtx, nil); timedOut != nil {
+		// A command that overran its deadline leaves the state as it was.
+		c.update = false
+		c.delete = false
+		c.state = state
+		return nil, timedOut
+	}
+	return reply, err
 }
 
 func (c *Context) Delete() {
@@ -181,10 +246,26 @@ func (c *Context) Update(state *any.Any, err error) error {
 	return nil
 }
 
+// writeBackMigrated updates the state for a migrated state to be written back
+// with the current reply. A failed command leaves it to the next command. A
+// command that updated or deleted the state itself already writes it back,
+// so nothing is left to be written.
+func (c *Context) writeBackMigrated() {
+	if !c.migrated || c.failure != nil {
+		return
+	}
+	c.migrated = false
+	if !c.delete {
+		c.update = true
+	}
+}
+
 func (c *Context) reset() {
 	c.update = false
 	c.delete = false
 	c.forward = nil
 	c.failure = nil
 	c.sideEffects = nil
+	c.metadata = nil
+	c.cmdCtx = nil
 }
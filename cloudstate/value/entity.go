@@ -16,6 +16,7 @@
 package value
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/cloudstateio/go-support/cloudstate/protocol"
@@ -32,8 +33,23 @@ type Entity struct {
 	PersistenceID string
 
 	PassivationStrategy protocol.EntityPassivationStrategy
+	// Migrations transform a persisted state of a previous type into its
+	// current type. They are keyed by the type URL of the state they migrate
+	// from and are applied as a chain until no migration matches anymore.
+	Migrations map[string]MigrationFunc
+	// WriteBackMigrations writes a migrated state back with the next command
+	// handled, unless that command updates or deletes the state itself.
+	WriteBackMigrations bool
+	// Interceptors intercept the commands of the entity.
+	Interceptors []protocol.Interceptor
+	// Timeout bounds the time a command is handled if positive. A command
+	// handler overrunning it fails the command.
+	Timeout time.Duration
 }
 
+// A MigrationFunc migrates a state of a previous type to a newer one.
+type MigrationFunc func(state *any.Any) (*any.Any, error)
+
 type Option func(s *Entity)
 
 func (e *Entity) Options(options ...Option) {
@@ -54,6 +70,65 @@ func WithPassivationStrategyTimeout(duration time.Duration) Option {
 	}
 }
 
+// WithStateMigration registers a migration for a state of the given type URL.
+func WithStateMigration(fromTypeURL string, migrate MigrationFunc) Option {
+	return func(e *Entity) {
+		if e.Migrations == nil {
+			e.Migrations = make(map[string]MigrationFunc)
+		}
+		e.Migrations[fromTypeURL] = migrate
+	}
+}
+
+// WithMigrationWriteBack enables migrated state to be written back.
+func WithMigrationWriteBack() Option {
+	return func(e *Entity) {
+		e.WriteBackMigrations = true
+	}
+}
+
+// WithInterceptors adds interceptors for the commands of the entity.
+func WithInterceptors(interceptors ...protocol.Interceptor) Option {
+	return func(e *Entity) {
+		e.Interceptors = append(e.Interceptors, interceptors...)
+	}
+}
+
+// WithTimeout bounds the time a command of the entity is handled. A
+// grpc-timeout or deadline metadata entry of a command may shorten it.
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Entity) {
+		e.Timeout = timeout
+	}
+}
+
+// migrate runs the migration chain for the given state and reports whether
+// the state was migrated.
+func (e *Entity) migrate(state *any.Any) (*any.Any, bool, error) {
+	migrated := false
+	seen := make(map[string]bool)
+	for {
+		typeURL := state.GetTypeUrl()
+		migrate, ok := e.Migrations[typeURL]
+		if !ok {
+			return state, migrated, nil
+		}
+		if seen[typeURL] {
+			return nil, false, fmt.Errorf("state migration cycle detected for type: %q", typeURL)
+		}
+		seen[typeURL] = true
+		next, err := migrate(state)
+		if err != nil {
+			return nil, false, fmt.Errorf("state migration from type: %q failed: %w", typeURL, err)
+		}
+		if next == nil {
+			return nil, false, fmt.Errorf("state migration from type: %q returned no state", typeURL)
+		}
+		state = next
+		migrated = true
+	}
+}
+
 type EntityHandler interface {
 	HandleCommand(ctx *Context, name string, msg proto.Message) (*any.Any, error)
 	HandleState(ctx *Context, state *any.Any) error
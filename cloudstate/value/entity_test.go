@@ -0,0 +1,281 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package value
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/cloudstate/protocol"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+func TestEntityMigrate(t *testing.T) {
+	t.Run("should apply migrations as a chain", func(t *testing.T) {
+		e := &Entity{}
+		e.Options(
+			WithStateMigration(encoding.PrimitiveTypeURLPrefixInt32, func(state *any.Any) (*any.Any, error) {
+				return encoding.Int64(int64(encoding.DecodeInt32(state))), nil
+			}),
+			WithStateMigration(encoding.PrimitiveTypeURLPrefixInt64, func(state *any.Any) (*any.Any, error) {
+				return encoding.Float64(float64(encoding.DecodeInt64(state))), nil
+			}),
+		)
+		state, migrated, err := e.migrate(encoding.Int32(7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !migrated {
+			t.Fatal("state should have been migrated")
+		}
+		if got := encoding.DecodeFloat64(state); got != 7 {
+			t.Fatalf("got: %v; want: %v", got, 7)
+		}
+	})
+
+	t.Run("should not migrate a current state", func(t *testing.T) {
+		e := &Entity{}
+		state, migrated, err := e.migrate(encoding.String("current"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if migrated {
+			t.Fatal("state should not have been migrated")
+		}
+		if got := encoding.DecodeString(state); got != "current" {
+			t.Fatalf("got: %v; want: %v", got, "current")
+		}
+	})
+
+	t.Run("should fail for a migration cycle", func(t *testing.T) {
+		e := &Entity{}
+		e.Options(
+			WithStateMigration(encoding.PrimitiveTypeURLPrefixBool, func(state *any.Any) (*any.Any, error) {
+				return encoding.String("true"), nil
+			}),
+			WithStateMigration(encoding.PrimitiveTypeURLPrefixString, func(state *any.Any) (*any.Any, error) {
+				return encoding.Bool(true), nil
+			}),
+		)
+		if _, _, err := e.migrate(encoding.Bool(true)); err == nil {
+			t.Fatal("expected a cycle to be detected")
+		}
+	})
+
+	t.Run("should fail for a failed migration", func(t *testing.T) {
+		errMigration := errors.New("migration failed")
+		e := &Entity{}
+		e.Options(WithStateMigration(encoding.PrimitiveTypeURLPrefixBool, func(state *any.Any) (*any.Any, error) {
+			return nil, errMigration
+		}))
+		if _, _, err := e.migrate(encoding.Bool(true)); !errors.Is(err, errMigration) {
+			t.Fatalf("got: %v; want: %v", err, errMigration)
+		}
+	})
+}
+
+func TestContextWriteBackMigrated(t *testing.T) {
+	state := encoding.String("migrated")
+	t.Run("should write back with the next command", func(t *testing.T) {
+		c := &Context{state: state, migrated: true}
+		c.writeBackMigrated()
+		if !c.update || c.migrated {
+			t.Fatalf("update: %v, migrated: %v", c.update, c.migrated)
+		}
+	})
+	t.Run("should not write back on a failed command", func(t *testing.T) {
+		c := &Context{state: state, migrated: true, failure: errors.New("failed")}
+		c.writeBackMigrated()
+		if c.update || !c.migrated {
+			t.Fatalf("update: %v, migrated: %v", c.update, c.migrated)
+		}
+	})
+	t.Run("should not write back over a delete", func(t *testing.T) {
+		c := &Context{state: state, migrated: true}
+		c.Delete()
+		c.writeBackMigrated()
+		if c.update || c.migrated {
+			t.Fatalf("update: %v, migrated: %v", c.update, c.migrated)
+		}
+	})
+}
+
+type interceptedEntity struct {
+	handled []string
+}
+
+func (e *interceptedEntity) HandleCommand(_ *Context, name string, _ proto.Message) (*any.Any, error) {
+	e.handled = append(e.handled, name)
+	return encoding.String(name), nil
+}
+
+func (e *interceptedEntity) HandleState(*Context, *any.Any) error {
+	return nil
+}
+
+func TestContextInterceptors(t *testing.T) {
+	forward := &protocol.Forward{ServiceName: "other", CommandName: "Call"}
+	redirect := func(ctx context.Context, cmd *protocol.InterceptedCommand, next protocol.CommandHandler) error {
+		if cmd.Name == "Redirected" {
+			cmd.Forward(forward)
+			return nil
+		}
+		return next(ctx, cmd)
+	}
+	newContext := func() (*Context, *interceptedEntity) {
+		e := &interceptedEntity{}
+		return &Context{
+			Entity:       &Entity{ServiceName: "service"},
+			Instance:     e,
+			ctx:          context.Background(),
+			interceptors: []protocol.Interceptor{redirect},
+		}, e
+	}
+
+	t.Run("should pass a command through", func(t *testing.T) {
+		c, e := newContext()
+		reply, err := c.handleCommand(&protocol.Command{Name: "Passed"}, encoding.String("msg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := encoding.DecodeString(reply); got != "Passed" || len(e.handled) != 1 {
+			t.Fatalf("reply: %v, handled: %v", got, e.handled)
+		}
+	})
+
+	t.Run("should forward a short-circuited command", func(t *testing.T) {
+		c, e := newContext()
+		reply, err := c.handleCommand(&protocol.Command{Name: "Redirected"}, encoding.String("msg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply != nil || len(e.handled) != 0 {
+			t.Fatalf("reply: %v, handled: %v", reply, e.handled)
+		}
+		if c.forward != forward {
+			t.Fatalf("c.forward: %v; want: %v", c.forward, forward)
+		}
+	})
+}
+
+type ctxKey struct{}
+
+type ctxEntity struct {
+	value interface{}
+}
+
+func (e *ctxEntity) HandleCommand(c *Context, _ string, _ proto.Message) (*any.Any, error) {
+	e.value = c.CommandCtx().Value(ctxKey{})
+	return encoding.Empty, nil
+}
+
+func (e *ctxEntity) HandleState(*Context, *any.Any) error {
+	return nil
+}
+
+func TestContextInterceptorCtx(t *testing.T) {
+	e := &ctxEntity{}
+	c := &Context{
+		Entity:   &Entity{ServiceName: "service"},
+		Instance: e,
+		ctx:      context.Background(),
+		interceptors: []protocol.Interceptor{func(ctx context.Context, cmd *protocol.InterceptedCommand, next protocol.CommandHandler) error {
+			return next(context.WithValue(ctx, ctxKey{}, "tenant"), cmd)
+		}},
+	}
+	if _, err := c.handleCommand(&protocol.Command{Name: "Tagged"}, encoding.String("msg")); err != nil {
+		t.Fatal(err)
+	}
+	if e.value != "tenant" {
+		t.Fatalf("value: %v; want: %v", e.value, "tenant")
+	}
+}
+
+type slowEntity struct{}
+
+func (e *slowEntity) HandleCommand(c *Context, _ string, _ proto.Message) (*any.Any, error) {
+	<-c.CommandCtx().Done()
+	return encoding.String("late"), nil
+}
+
+func (e *slowEntity) HandleState(*Context, *any.Any) error {
+	return nil
+}
+
+func TestContextTimeout(t *testing.T) {
+	c := &Context{
+		Entity:   &Entity{ServiceName: "service", Timeout: time.Second},
+		Instance: &slowEntity{},
+		ctx:      context.Background(),
+	}
+	md := &protocol.Metadata{}
+	md.Set(protocol.GrpcTimeout, "1m")
+	_, err := c.handleCommand(&protocol.Command{Name: "Slow", Metadata: md}, encoding.String("msg"))
+	if !errors.Is(err, protocol.ClientError{}) || !errors.Is(err, protocol.ErrCommandTimeout) {
+		t.Fatalf("err: %v; want a client error of ErrCommandTimeout", err)
+	}
+}
+
+type slowUpdateEntity struct{}
+
+func (e *slowUpdateEntity) HandleCommand(c *Context, _ string, _ proto.Message) (*any.Any, error) {
+	if err := c.Update(encoding.String("updated"), nil); err != nil {
+		return nil, err
+	}
+	<-c.CommandCtx().Done()
+	return encoding.String("late"), nil
+}
+
+func (e *slowUpdateEntity) HandleState(*Context, *any.Any) error {
+	return nil
+}
+
+func TestContextTimeoutKeepsState(t *testing.T) {
+	state := encoding.String("state")
+	c := &Context{
+		Entity:   &Entity{ServiceName: "service", Timeout: 10 * time.Millisecond},
+		Instance: &slowUpdateEntity{},
+		ctx:      context.Background(),
+		state:    state,
+	}
+	if _, err := c.handleCommand(&protocol.Command{Name: "Slow"}, encoding.String("msg")); !errors.Is(err, protocol.ErrCommandTimeout) {
+		t.Fatalf("err: %v; want: %v", err, protocol.ErrCommandTimeout)
+	}
+	if c.update || c.state != state {
+		t.Fatalf("update: %v, state: %v; want the state as it was", c.update, c.state)
+	}
+}
+
+func TestContextInterceptorTimeout(t *testing.T) {
+	c := &Context{
+		Entity:   &Entity{ServiceName: "service"},
+		Instance: &slowEntity{},
+		ctx:      context.Background(),
+		interceptors: []protocol.Interceptor{func(ctx context.Context, cmd *protocol.InterceptedCommand, next protocol.CommandHandler) error {
+			ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+			return next(ctx, cmd)
+		}},
+	}
+	_, err := c.handleCommand(&protocol.Command{Name: "Slow"}, encoding.String("msg"))
+	if !errors.Is(err, protocol.ClientError{}) || !errors.Is(err, protocol.ErrCommandTimeout) {
+		t.Fatalf("err: %v; want a client error of ErrCommandTimeout", err)
+	}
+}
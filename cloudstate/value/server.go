@@ -41,6 +41,11 @@ type Server struct {
 	// entities has descriptions of entities registered by service names
 	entities map[ServiceName]*Entity
 
+	// interceptors intercept commands of all entities.
+	interceptors []protocol.Interceptor
+	// targets validates forward and side effect targets, if set.
+	targets *protocol.TargetValidation
+
 	entity.UnimplementedValueEntityServer
 }
 
@@ -63,6 +68,34 @@ func (s *Server) Register(e *Entity) error {
 	return nil
 }
 
+// Intercept adds interceptors for commands of all entities of the server.
+// They run before the interceptors of an entity.
+func (s *Server) Intercept(interceptors ...protocol.Interceptor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interceptors = append(s.interceptors, interceptors...)
+}
+
+func (s *Server) globalInterceptors() []protocol.Interceptor {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.interceptors
+}
+
+// ValidateTargets sets the validation of forward and side effect targets
+// for all entities of the server.
+func (s *Server) ValidateTargets(v *protocol.TargetValidation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets = v
+}
+
+func (s *Server) targetValidation() *protocol.TargetValidation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.targets
+}
+
 func (s *Server) Handle(stream entity.ValueEntity_HandleServer) error {
 	init, err := stream.Recv()
 	if err != nil {
@@ -85,13 +118,20 @@ func (s *Server) Handle(stream entity.ValueEntity_HandleServer) error {
 		Instance: e.EntityFunc(id),
 		ctx:      stream.Context(),
 	}
+	c.interceptors = protocol.Interceptors(s.globalInterceptors(), e.Interceptors)
+	c.targets = s.targetValidation()
 
 	if state := init.GetInit().GetState().GetValue(); state != nil {
+		state, migrated, err := e.migrate(state)
+		if err != nil {
+			return err
+		}
 		err = c.Instance.HandleState(c, state)
 		if err != nil {
 			return err
 		}
 		c.state = state
+		c.migrated = migrated && e.WriteBackMigrations
 	}
 	for {
 		msg, err := stream.Recv()
@@ -104,10 +144,14 @@ func (s *Server) Handle(stream entity.ValueEntity_HandleServer) error {
 		switch m := msg.GetMessage().(type) {
 		case *entity.ValueEntityStreamIn_Command:
 			reply, err := c.runCommand(m.Command)
+			if err == nil {
+				err = c.targets.Check(c.forward, c.sideEffects)
+			}
 			if err != nil && !errors.Is(err, protocol.ClientError{}) {
 				return err
 			}
 			c.failure = err
+			c.writeBackMigrated()
 			err = stream.Send(&entity.ValueEntityStreamOut{
 				Message: &entity.ValueEntityStreamOut_Reply{
 					Reply: c.entityReply(m.Command, reply),
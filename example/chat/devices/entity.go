@@ -0,0 +1,74 @@
+//
+// Copyright 2019 Lightbend Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package devices implements the chat presence service with the presence
+// component, which tracks the connected devices of a user. It uses an
+// ORMap as its state and, as the CRDT type of an entity can't be changed,
+// has to be deployed as a new service instead of the Vote based one.
+package devices
+
+import (
+	"github.com/cloudstateio/go-support/cloudstate/crdt"
+	crdtpresence "github.com/cloudstateio/go-support/cloudstate/crdt/presence"
+	"github.com/cloudstateio/go-support/cloudstate/encoding"
+	"github.com/cloudstateio/go-support/example/chat/presence"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/empty"
+)
+
+// deviceKey is the metadata key a client sends its device id with.
+const deviceKey = "device-id"
+
+// Entity returns the presence entity for the service.
+func Entity(serviceName crdt.ServiceName) *crdt.Entity {
+	return crdtpresence.Entity(serviceName, handleCommand)
+}
+
+func handleCommand(ctx *crdt.CommandContext, p *crdtpresence.Presence, name string, msg proto.Message) (*any.Any, error) {
+	switch name {
+	case "Connect":
+		return connect(ctx, p, msg.(*presence.User))
+	case "Monitor":
+		return monitor(ctx, p)
+	}
+	return nil, nil
+}
+
+func connect(ctx *crdt.CommandContext, p *crdtpresence.Presence, user *presence.User) (*any.Any, error) {
+	if ctx.Streamed() {
+		if err := p.Connect(ctx, device(ctx, user), nil); err != nil {
+			return nil, err
+		}
+	}
+	return encoding.MarshalAny(&empty.Empty{})
+}
+
+func monitor(ctx *crdt.CommandContext, p *crdtpresence.Presence) (*any.Any, error) {
+	return p.Monitor(ctx, func(online bool) (*any.Any, error) {
+		return encoding.MarshalAny(&presence.OnlineStatus{Online: online})
+	})
+}
+
+// device returns the device id sent with the command. Clients not sending
+// one are taken as a single device named after the user.
+func device(ctx *crdt.CommandContext, user *presence.User) string {
+	for _, e := range ctx.Metadata().GetEntries() {
+		if e.GetKey() == deviceKey && e.GetStringValue() != "" {
+			return e.GetStringValue()
+		}
+	}
+	return user.Name
+}
@@ -17,13 +17,7 @@ type EventLogSubscriberModel struct {
 }
 
 func (e *EventLogSubscriberModel) HandleCommand(ctx *action.Context, name string, msg proto.Message) error {
-	var id = ""
-	for _, entry := range ctx.Metadata().GetEntries() {
-		if entry.GetKey() == "ce-subject" {
-			id = entry.GetStringValue()
-			break
-		}
-	}
+	id := ctx.Metadata().CloudEvent().Subject()
 	switch name {
 	case "Effect":
 		r, err := encoding.MarshalAny(&Response{
@@ -69,12 +63,7 @@ func (e *EventLogSubscriberModel) HandleCommand(ctx *action.Context, name string
 }
 
 func convert(ctx *action.Context, metadata *protocol.Metadata, step *ProcessStep) error {
-	var id = ""
-	for _, entry := range metadata.GetEntries() {
-		if entry.GetKey() == "ce-subject" {
-			id = entry.GetStringValue()
-		}
-	}
+	id := metadata.CloudEvent().Subject()
 	if step.GetReply() != nil {
 		x, err := encoding.MarshalAny(&Response{
 			Id:      id,